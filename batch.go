@@ -18,6 +18,14 @@ type WriteBatch struct {
 	bitCaskDB     *DB
 	options       WriteBatchOptions
 	pendingWrites map[string]*data.LogRecord
+	conditions    []*batchCondition // all must hold at commit
+}
+
+// value of key expected at commit, nil means key doesn't exist
+type batchCondition struct {
+	key       []byte
+	expected  []byte
+	mustExist bool // key must exist, so nil expected never matches
 }
 
 func (db *DB) NewWriteBatch(opts WriteBatchOptions) *WriteBatch {
@@ -59,7 +67,10 @@ func (wb *WriteBatch) Delete(key []byte) error {
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
 
-	pos := wb.bitCaskDB.index.Get(key)
+	pos, err := wb.bitCaskDB.index.Get(key)
+	if err != nil {
+		return err
+	}
 
 	// if delete un-commit or un-exist logrecord
 	if pos == nil {
@@ -76,7 +87,46 @@ func (wb *WriteBatch) Delete(key []byte) error {
 	return nil
 }
 
-// commit pending writes to disk file and index
+// put kv to pending write, commit fails if current value of key doesn't equal to expected
+func (wb *WriteBatch) CompareAndSwap(key, expected, value []byte) error {
+	if err := wb.Put(key, value); err != nil {
+		return err
+	}
+
+	wb.addCondition(key, expected)
+	return nil
+}
+
+// put kv to pending write, commit fails if key exists
+func (wb *WriteBatch) PutIfAbsent(key, value []byte) error {
+	return wb.CompareAndSwap(key, nil, value)
+}
+
+// put delete entry to pending write, commit fails if key doesn't exist
+// or its value doesn't equal to expected
+func (wb *WriteBatch) DeleteIfEqual(key, expected []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	wb.pendingWrites[string(key)] = &data.LogRecord{Key: key, Type: data.LogRecordDelete}
+	wb.conditions = append(wb.conditions, &batchCondition{key: key, expected: expected, mustExist: true})
+
+	return nil
+}
+
+func (wb *WriteBatch) addCondition(key, expected []byte) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	wb.conditions = append(wb.conditions, &batchCondition{key: key, expected: expected})
+}
+
+// commit pending writes to disk file and index,
+// return ErrConditionFailed and write nothing if any condition doesn't hold
 func (wb *WriteBatch) Commit() error {
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
@@ -89,23 +139,54 @@ func (wb *WriteBatch) Commit() error {
 		return ErrExceedMaxBatch
 	}
 
-	wb.bitCaskDB.mu.Lock()
-	defer wb.bitCaskDB.mu.Unlock()
+	syncWrites := wb.options.SynWrites || wb.bitCaskDB.options.SyncWrite
+	err := wb.bitCaskDB.update(syncWrites, func() error {
+		for _, cond := range wb.conditions {
+			if cond.mustExist && cond.expected == nil {
+				return ErrConditionFailed
+			}
+			ok, err := wb.bitCaskDB.matchValue(cond.key, cond.expected)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrConditionFailed
+			}
+		}
+
+		_, err := wb.bitCaskDB.commitPendingWrites(wb.pendingWrites)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// clear pendingWrites
+	wb.pendingWrites = make(map[string]*data.LogRecord)
+	wb.conditions = nil
+
+	return nil
+}
 
+// append pending writes as one transaction to active file and update index,
+// records are tagged with a new sequence number and followed by a fin record,
+// return sequence number of the transaction
+// caller must hold db.mu, records are persisted by caller if needed
+func (db *DB) commitPendingWrites(pendingWrites map[string]*data.LogRecord) (uint64, error) {
 	// increase sequence nubmer as current transaction number
-	txnSeq := atomic.AddUint64(&wb.bitCaskDB.txnSeqNo, 1)
+	txnSeq := atomic.AddUint64(&db.txnSeqNo, 1)
 
 	postions := make(map[string]*data.LogRecordPos)
-	for _, record := range wb.pendingWrites {
+	for _, record := range pendingWrites {
 		log := &data.LogRecord{
 			Key:   logRecordKeyWithSeq(record.Key, txnSeq),
 			Value: record.Value,
 			Type:  record.Type,
 		}
 
-		pos, err := wb.bitCaskDB.appendLogRecord(log)
+		pos, err := db.appendLogRecord(log)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		postions[string(record.Key)] = pos
@@ -117,27 +198,19 @@ func (wb *WriteBatch) Commit() error {
 		Type: data.LogRecordTxnFin,
 	}
 
-	if _, err := wb.bitCaskDB.appendLogRecord(commitRecord); err != nil {
-		return err
-	}
-
-	// persist
-	if wb.options.SynWrites && wb.bitCaskDB.activeFile != nil {
-		if err := wb.bitCaskDB.activeFile.Sync(); err != nil {
-			return err
-		}
+	if _, err := db.appendLogRecord(commitRecord); err != nil {
+		return 0, err
 	}
 
 	// batch update index
-	for _, record := range wb.pendingWrites {
+	for _, record := range pendingWrites {
 		pos := postions[string(record.Key)]
-		wb.bitCaskDB.updateIndex(record.Key, record.Type, pos)
+		if err := db.updateIndex(record.Key, record.Type, pos); err != nil {
+			return 0, err
+		}
 	}
 
-	// clear pendingWrites
-	wb.pendingWrites = make(map[string]*data.LogRecord)
-
-	return nil
+	return txnSeq, nil
 }
 
 // encode seq+key to bytes
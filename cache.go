@@ -0,0 +1,127 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"container/list"
+	"sync"
+)
+
+// ---- value cache of hot reads ----
+//
+// decoded values are cached by position of record, a key written again gets
+// a new position so its old entry is never hit and ages out of lru.
+// merge reuses file ids, entries of merged files are removed when installing them
+
+// memory taken by an entry besides its value
+const valueCacheEntryOverhead = 64
+
+type valueCacheKey struct {
+	fileId uint32
+	offset int64
+}
+
+type valueCacheEntry struct {
+	key   valueCacheKey
+	value []byte
+}
+
+// lru cache bounded by bytes
+type valueCache struct {
+	mu       *sync.Mutex
+	capacity int64
+	size     int64
+	entries  map[valueCacheKey]*list.Element
+	lru      *list.List // front is most recently used
+	hits     uint64
+	misses   uint64
+}
+
+// return nil if capacity is 0
+func newValueCache(capacity int64) *valueCache {
+	if capacity <= 0 {
+		return nil
+	}
+
+	return &valueCache{
+		mu:       new(sync.Mutex),
+		capacity: capacity,
+		entries:  make(map[valueCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// return a copy of cached value at pos
+func (c *valueCache) get(pos *data.LogRecordPos) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[valueCacheKey{pos.FileId, pos.Offset}]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+	value := elem.Value.(*valueCacheEntry).value
+	return append([]byte{}, value...), true
+}
+
+// cache a copy of value at pos, least recently used entries are evicted
+func (c *valueCache) put(pos *data.LogRecordPos, value []byte) {
+	size := int64(len(value)) + valueCacheEntryOverhead
+	if size > c.capacity {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := valueCacheKey{pos.FileId, pos.Offset}
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	entry := &valueCacheEntry{key: key, value: append([]byte{}, value...)}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += size
+
+	for c.size > c.capacity {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// remove entries of files before fileId
+func (c *valueCache) removeBefore(fileId uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.entries {
+		if key.fileId < fileId {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *valueCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[valueCacheKey]*list.Element)
+	c.lru.Init()
+	c.size = 0
+}
+
+func (c *valueCache) stat() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// caller must hold c.mu
+func (c *valueCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*valueCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.value)) + valueCacheEntryOverhead
+}
@@ -0,0 +1,108 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueCache(t *testing.T) {
+	cache := newValueCache(3 * (valueCacheEntryOverhead + 10))
+	pos := func(fid uint32, offset int64) *data.LogRecordPos {
+		return &data.LogRecordPos{FileId: fid, Offset: offset}
+	}
+
+	for i := 0; i < 3; i++ {
+		cache.put(pos(uint32(i), 0), []byte("0123456789"))
+	}
+	_, ok := cache.get(pos(0, 0))
+	assert.True(t, ok)
+
+	// least recently used entry is evicted
+	cache.put(pos(3, 0), []byte("0123456789"))
+	_, ok = cache.get(pos(1, 0))
+	assert.False(t, ok)
+	value, ok := cache.get(pos(3, 0))
+	assert.True(t, ok)
+	assert.Equal(t, []byte("0123456789"), value)
+
+	// value returned is a copy
+	value[0] = 'x'
+	value, _ = cache.get(pos(3, 0))
+	assert.Equal(t, []byte("0123456789"), value)
+
+	// value larger than capacity isn't cached
+	cache.put(pos(4, 0), make([]byte, 1024))
+	_, ok = cache.get(pos(4, 0))
+	assert.False(t, ok)
+
+	cache.removeBefore(3)
+	_, ok = cache.get(pos(0, 0))
+	assert.False(t, ok)
+	_, ok = cache.get(pos(3, 0))
+	assert.True(t, ok)
+
+	hits, misses := cache.stat()
+	assert.Equal(t, uint64(4), hits)
+	assert.Equal(t, uint64(3), misses)
+}
+
+func TestDB_ValueCache(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-value-cache")
+	opts.DirPath = dir
+	opts.Maxsize = 64 * 1024
+	opts.ValueCacheSize = 1024 * 1024
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put(utils.GetTestKey(1), []byte("v1")))
+	for i := 0; i < 2; i++ {
+		value, err := db.Get(utils.GetTestKey(1))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("v1"), value)
+	}
+	stat := db.Stat()
+	assert.Equal(t, uint64(1), stat.CacheHits)
+	assert.Equal(t, uint64(1), stat.CacheMisses)
+
+	// new value has a new position
+	assert.Nil(t, db.Put(utils.GetTestKey(1), []byte("v2")))
+	value, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), value)
+	assert.Equal(t, uint64(2), db.Stat().CacheMisses)
+
+	// merged files reuse file ids of cached entries
+	for i := 0; i < 2000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestKey(i+10000)))
+	}
+	for i := 0; i < 2000; i++ {
+		_, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 2000; i += 2 {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	assert.Nil(t, db.Merge())
+
+	for i := 0; i < 2000; i++ {
+		value, err := db.Get(utils.GetTestKey(i))
+		if i%2 == 0 {
+			assert.Equal(t, ErrKeyNotFound, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, utils.GetTestKey(i+10000), value)
+		}
+	}
+
+	values, errs := db.MultiGet([][]byte{utils.GetTestKey(1), utils.GetTestKey(3)})
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.Equal(t, utils.GetTestKey(10001), values[0])
+	assert.Equal(t, utils.GetTestKey(10003), values[1])
+}
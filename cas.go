@@ -0,0 +1,81 @@
+package bitcaskgo
+
+import "bytes"
+
+// ---- conditional writes ----
+//
+// value of key is checked and written under db.mu, so conditional writes are
+// linearizable with other writers. expected value nil means key doesn't exist,
+// an empty value is different from a missing key
+
+// put value if current value of key equals to expected,
+// return false if current value doesn't match
+func (db *DB) CompareAndSwap(key, expected, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+
+	var swapped bool
+	err := db.update(db.options.SyncWrite, func() error {
+		ok, err := db.matchValue(key, expected)
+		if err != nil || !ok {
+			return err
+		}
+
+		swapped = true
+		return db.appendPut(key, value, 0)
+	})
+
+	return swapped && err == nil, err
+}
+
+// put value if key doesn't exist, return false if key exists
+func (db *DB) PutIfAbsent(key, value []byte) (bool, error) {
+	return db.CompareAndSwap(key, nil, value)
+}
+
+// delete key if its value equals to expected,
+// return false if key doesn't exist or value doesn't match
+func (db *DB) DeleteIfEqual(key, expected []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyIsEmpty
+	}
+	if expected == nil {
+		return false, nil
+	}
+
+	var deleted bool
+	err := db.update(db.options.SyncWrite, func() error {
+		ok, err := db.matchValue(key, expected)
+		if err != nil || !ok {
+			return err
+		}
+
+		deleted = true
+		return db.appendDelete(key)
+	})
+
+	return deleted && err == nil, err
+}
+
+// check current value of key, expected nil matches a missing or expired key
+// caller must hold db.mu
+func (db *DB) matchValue(key, expected []byte) (bool, error) {
+	pos, err := db.index.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if pos == nil || pos.Expired() {
+		return expected == nil, nil
+	}
+	if expected == nil {
+		return false, nil
+	}
+
+	value, err := db.getValueByPostion(pos)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(value, expected), nil
+}
@@ -0,0 +1,151 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/utils"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_CompareAndSwap(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-cas")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	key := utils.GetTestKey(1)
+	ok, err := db.PutIfAbsent(key, []byte("a"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = db.PutIfAbsent(key, []byte("b"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = db.CompareAndSwap(key, []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = db.CompareAndSwap(key, []byte("a"), []byte("c"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	val, err := db.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("c"), val)
+
+	// empty value is different from missing key
+	ok, err = db.CompareAndSwap(key, []byte("c"), []byte{})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = db.PutIfAbsent(key, []byte("d"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = db.DeleteIfEqual(key, []byte("d"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = db.DeleteIfEqual(key, []byte{})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = db.Get(key)
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// expired key is treated as missing
+	assert.Nil(t, db.PutWithTTL(key, []byte("lease"), 50*time.Millisecond))
+	time.Sleep(100 * time.Millisecond)
+	ok, err = db.PutIfAbsent(key, []byte("new-lease"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestDB_CompareAndSwapConcurrent(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-cas")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	key := []byte("counter")
+	assert.Nil(t, db.Put(key, []byte("0")))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				for {
+					val, err := db.Get(key)
+					assert.Nil(t, err)
+					n, _ := strconv.Atoi(string(val))
+					ok, err := db.CompareAndSwap(key, val, []byte(strconv.Itoa(n+1)))
+					assert.Nil(t, err)
+					if ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	val, err := db.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("800"), val)
+}
+
+func TestWriteBatch_Conditions(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-cas")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put(utils.GetTestKey(1), []byte("1")))
+	assert.Nil(t, db.Put(utils.GetTestKey(2), []byte("2")))
+
+	// one failed condition fails the whole batch
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.CompareAndSwap(utils.GetTestKey(1), []byte("1"), []byte("10")))
+	assert.Nil(t, wb.PutIfAbsent(utils.GetTestKey(2), []byte("20")))
+	assert.Nil(t, wb.Put(utils.GetTestKey(3), []byte("30")))
+	assert.Equal(t, ErrConditionFailed, wb.Commit())
+
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), val)
+	_, err = db.Get(utils.GetTestKey(3))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	wb = db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.CompareAndSwap(utils.GetTestKey(1), []byte("1"), []byte("10")))
+	assert.Nil(t, wb.DeleteIfEqual(utils.GetTestKey(2), []byte("2")))
+	assert.Nil(t, wb.PutIfAbsent(utils.GetTestKey(3), []byte("30")))
+	assert.Nil(t, wb.Commit())
+
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("10"), val)
+	_, err = db.Get(utils.GetTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db.Get(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("30"), val)
+
+	// deleting a missing key fails even if expected is nil
+	wb = db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.DeleteIfEqual(utils.GetTestKey(2), nil))
+	assert.Equal(t, ErrConditionFailed, wb.Commit())
+	wb = db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.DeleteIfEqual(utils.GetTestKey(3), nil))
+	assert.Equal(t, ErrConditionFailed, wb.Commit())
+	val, err = db.Get(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("30"), val)
+}
@@ -0,0 +1,197 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/index"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// position of log records which has been applied to persistent keydir,
+// db only needs to replay records after it at startup
+type indexCheckpoint struct {
+	fileId      uint32
+	offset      int64
+	txnSeqNo    uint64
+	reclaimSize int64
+}
+
+func (cp *indexCheckpoint) encode() []byte {
+	buf := make([]byte, binary.MaxVarintLen32+binary.MaxVarintLen64*3)
+	var index = 0
+	index += binary.PutUvarint(buf[index:], uint64(cp.fileId))
+	index += binary.PutVarint(buf[index:], cp.offset)
+	index += binary.PutUvarint(buf[index:], cp.txnSeqNo)
+	index += binary.PutVarint(buf[index:], cp.reclaimSize)
+
+	return buf[:index]
+}
+
+func decodeIndexCheckpoint(buf []byte) *indexCheckpoint {
+	cp := &indexCheckpoint{}
+	var index = 0
+
+	fileId, n := binary.Uvarint(buf[index:])
+	index += n
+	cp.fileId = uint32(fileId)
+
+	cp.offset, n = binary.Varint(buf[index:])
+	index += n
+
+	cp.txnSeqNo, n = binary.Uvarint(buf[index:])
+	index += n
+
+	cp.reclaimSize, _ = binary.Varint(buf[index:])
+
+	return cp
+}
+
+// create keydir of db
+// persistent keydir is rebuilt if it has no checkpoint or merge files are loaded
+// read-only db builds keydir in memory, index file is owned by writer,
+// in-memory db has no index file
+func (db *DB) openIndex(merged bool) error {
+	if db.options.Index != index.BPLUSTREE || db.options.ReadOnly || db.options.InMemory {
+		indexType := db.options.Index
+		if indexType == index.BPLUSTREE {
+			indexType = index.BTREE
+		}
+		keydir, err := index.NewIndexer(indexType, db.options.DirPath)
+		if err != nil {
+			return err
+		}
+		db.index = keydir
+		return nil
+	}
+
+	indexFileName := filepath.Join(db.options.DirPath, index.BPlusTreeFileName)
+	if merged {
+		// positions in merged files has been changed
+		if err := os.RemoveAll(indexFileName); err != nil {
+			return err
+		}
+	}
+
+	keydir, err := index.NewBPlusTree(db.options.DirPath)
+	if err != nil {
+		return err
+	}
+
+	buf, err := keydir.LoadCheckpoint()
+	if err != nil {
+		_ = keydir.Close()
+		return err
+	}
+
+	if buf == nil {
+		// keydir may contain some updates which has not been persisted to data file
+		logrus.Infof("[Bitcask] no checkpoint found, rebuild index file %v", indexFileName)
+		if keydir, err = db.recreateIndexFile(keydir); err != nil {
+			return err
+		}
+	} else {
+		db.checkpoint = decodeIndexCheckpoint(buf)
+		db.reclaimSize = db.checkpoint.reclaimSize
+	}
+
+	db.index = keydir
+	return nil
+}
+
+// remove index file and create an empty keydir
+func (db *DB) recreateIndexFile(keydir index.Indexer) (*index.BPlusTree, error) {
+	if err := keydir.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(filepath.Join(db.options.DirPath, index.BPlusTreeFileName)); err != nil {
+		return nil, err
+	}
+
+	return index.NewBPlusTree(db.options.DirPath)
+}
+
+// checkpoint is saved after data files are synced, but data files may be
+// replaced by an older copy or lose their tail, keydir is rebuilt if
+// checkpoint is beyond the end of data files
+// caller must hold db.mu
+func (db *DB) checkIndexCheckpoint() error {
+	if db.checkpoint == nil {
+		return nil
+	}
+
+	// checkpoint of empty db
+	if db.activeFile == nil && db.checkpoint.offset == 0 {
+		return nil
+	}
+
+	var dataFile *data.DataFile
+	if db.activeFile != nil && db.activeFile.FileId == db.checkpoint.fileId {
+		dataFile = db.activeFile
+	} else {
+		dataFile = db.olderFiles[db.checkpoint.fileId]
+	}
+	if dataFile != nil {
+		fileSize, err := dataFile.Size()
+		if err != nil {
+			return err
+		}
+		if db.checkpoint.offset <= fileSize {
+			return nil
+		}
+	}
+
+	logrus.Warnf("[Bitcask] checkpoint <fid:%v, off:%v> is beyond data files, rebuild index file",
+		db.checkpoint.fileId, db.checkpoint.offset)
+	keydir, err := db.recreateIndexFile(db.index)
+	if err != nil {
+		db.index = nil
+		return err
+	}
+	db.index = keydir
+	db.checkpoint = nil
+	db.reclaimSize = 0
+
+	return nil
+}
+
+// save position of active file to persistent keydir
+// caller must hold db.mu
+func (db *DB) saveIndexCheckpoint() error {
+	keydir, ok := db.index.(index.PersistentIndexer)
+	if !ok {
+		return nil
+	}
+
+	// records before checkpoint must be on disk, buffered writes are flushed by sync
+	if db.activeFile != nil {
+		if err := db.activeFile.Sync(); err != nil {
+			return err
+		}
+	}
+
+	cp := &indexCheckpoint{
+		txnSeqNo:    db.txnSeqNo,
+		reclaimSize: db.reclaimSize,
+	}
+	if db.activeFile != nil {
+		cp.fileId = db.activeFile.FileId
+		cp.offset = db.activeFile.WriteOff
+	}
+
+	return keydir.SaveCheckpoint(cp.encode())
+}
+
+// remove checkpoint of persistent keydir, it's saved again by Close.
+// keydir is updated before its changes are fsynced, so it must be rebuilt
+// if db crashes before closing
+func (db *DB) removeIndexCheckpoint() error {
+	keydir, ok := db.index.(index.PersistentIndexer)
+	if !ok {
+		return nil
+	}
+
+	return keydir.RemoveCheckpoint()
+}
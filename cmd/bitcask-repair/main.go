@@ -0,0 +1,91 @@
+package main
+
+import (
+	bitcaskgo "bitcask-go"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parse encrypt keys formatted as id:hexkey,id:hexkey
+func parseEncryptKeys(s string) (map[uint32][]byte, error) {
+	keys := make(map[uint32][]byte)
+	if s == "" {
+		return keys, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid encrypt key %q", item)
+		}
+		id, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		key, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		keys[uint32(id)] = key
+	}
+
+	return keys, nil
+}
+
+// check a bitcask dir which is not opened by other process,
+// valid records are rewritten to a clean dir if -repair-to is set
+//
+//	bitcask-repair -dir /tmp/bitcask-go
+//	bitcask-repair -dir /tmp/bitcask-go -repair-to /tmp/bitcask-go-repaired
+//	bitcask-repair -dir /tmp/bitcask-go -keys 1:00112233445566778899aabbccddeeff -key-id 1
+func main() {
+	dir := flag.String("dir", "", "bitcask data dir to verify")
+	repairTo := flag.String("repair-to", "", "rewrite valid records to this empty dir")
+	keys := flag.String("keys", "", "encrypt keys formatted as id:hexkey,id:hexkey")
+	keyId := flag.Uint("key-id", 0, "id of encrypt key used by repaired dir")
+	flag.Parse()
+
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := bitcaskgo.DefaultOptions
+	opts.DirPath = *dir
+	encryptKeys, err := parseEncryptKeys(*keys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "parse encrypt keys failed: %v\n", err)
+		os.Exit(2)
+	}
+	opts.EncryptKeys = encryptKeys
+	opts.EncryptKeyId = uint32(*keyId)
+
+	report, err := bitcaskgo.Verify(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify %v failed: %v\n", *dir, err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("data files: %v, records: %v, hint records: %v, issues: %v\n",
+		report.DataFiles, report.Records, report.HintRecords, len(report.Issues))
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+
+	if *repairTo != "" {
+		if _, err := bitcaskgo.Repair(opts, *repairTo); err != nil {
+			fmt.Fprintf(os.Stderr, "repair %v to %v failed: %v\n", *dir, *repairTo, err)
+			os.Exit(2)
+		}
+		fmt.Printf("valid records are rewritten to %v\n", *repairTo)
+		return
+	}
+
+	if len(report.Issues) > 0 {
+		os.Exit(1)
+	}
+}
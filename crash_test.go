@@ -0,0 +1,245 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/fio"
+	"bitcask-go/utils"
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openCrashTestDB(t *testing.T, name string, syncWrite bool) (*DB, *fio.FaultInjector) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", name)
+	opts.DirPath = dir
+	opts.Maxsize = 64 * 1024
+	opts.SyncWrite = syncWrite
+	fi := fio.NewFaultInjector()
+	opts.IOWrapper = fi.Wrap
+
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+	return db, fi
+}
+
+// crash db and open it again with the same options
+func crashAndReopen(t *testing.T, db *DB, fi *fio.FaultInjector) *DB {
+	assert.Nil(t, fi.Crash())
+	_ = db.Close()
+
+	reopened, err := OpenDB(db.options)
+	assert.Nil(t, err)
+	return reopened
+}
+
+func crashTestValue(i int) []byte {
+	return bytes.Repeat(utils.GetTestKey(i), 10)
+}
+
+func TestDB_CrashUnsyncedWrites(t *testing.T) {
+	db, fi := openCrashTestDB(t, "bitcask-go-crash-unsynced", false)
+
+	for i := 0; i < 500; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), crashTestValue(i)))
+	}
+	assert.Nil(t, db.Sync())
+	for i := 500; i < 2000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), crashTestValue(i)))
+	}
+
+	db = crashAndReopen(t, db, fi)
+	defer destroyDB(db)
+
+	// synced records survive, unsynced records are lost from some point
+	lost := -1
+	for i := 0; i < 2000; i++ {
+		value, err := db.Get(utils.GetTestKey(i))
+		if lost >= 0 || err == ErrKeyNotFound {
+			assert.Greater(t, i, 499)
+			assert.Equal(t, ErrKeyNotFound, err)
+			lost = i
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, crashTestValue(i), value)
+	}
+	// files rotated are synced
+	assert.Greater(t, lost, 500)
+}
+
+func TestDB_CrashSyncWrites(t *testing.T) {
+	db, fi := openCrashTestDB(t, "bitcask-go-crash-sync", true)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), crashTestValue(i)))
+	}
+
+	fi.FailSync(true)
+	assert.Equal(t, fio.ErrInjectedFault, db.Put(utils.GetTestKey(1000), crashTestValue(1000)))
+
+	// record which may not be persisted is never served, db rejects everything until reopened
+	fi.FailSync(false)
+	_, err := db.Get(utils.GetTestKey(1000))
+	assert.True(t, errors.Is(err, ErrSyncFailed))
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.True(t, errors.Is(err, ErrSyncFailed))
+	err = db.Put(utils.GetTestKey(1001), crashTestValue(1001))
+	assert.True(t, errors.Is(err, ErrSyncFailed))
+
+	db = crashAndReopen(t, db, fi)
+	defer destroyDB(db)
+
+	for i := 0; i < 1000; i++ {
+		value, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, crashTestValue(i), value)
+	}
+}
+
+func TestDB_CrashTornWrite(t *testing.T) {
+	db, fi := openCrashTestDB(t, "bitcask-go-crash-torn", true)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), crashTestValue(i)))
+	}
+
+	fi.FailWritesAfter(10)
+	writeOff := db.activeFile.WriteOff
+	assert.Equal(t, fio.ErrInjectedFault, db.Put(utils.GetTestKey(100), crashTestValue(100)))
+	_, err := db.Get(utils.GetTestKey(100))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// bytes of torn write are dropped, later records follow the last complete one
+	assert.Equal(t, writeOff, db.activeFile.WriteOff)
+	size, err := db.activeFile.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, writeOff, size)
+	fi.FailWritesAfter(-1)
+	for i := 101; i < 200; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), crashTestValue(i)))
+	}
+	for i := 101; i < 200; i++ {
+		value, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, crashTestValue(i), value)
+	}
+
+	// no corrupted tail is left to truncate
+	db.options.TruncateCorruptTail = false
+	db = crashAndReopen(t, db, fi)
+	defer destroyDB(db)
+
+	for i := 0; i < 200; i++ {
+		value, err := db.Get(utils.GetTestKey(i))
+		if i == 100 {
+			assert.Equal(t, ErrKeyNotFound, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, crashTestValue(i), value)
+	}
+}
+
+func TestDB_CrashWriteBatch(t *testing.T) {
+	db, fi := openCrashTestDB(t, "bitcask-go-crash-batch", true)
+
+	// batch is torn at different points, it's applied entirely or not at all
+	budgets := []int64{0, 30, 500, 2000, -1}
+	for round, budget := range budgets {
+		fi.FailWritesAfter(budget)
+		wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+		for i := 0; i < 50; i++ {
+			assert.Nil(t, wb.Put(utils.GetTestKey(round*100+i), crashTestValue(round*100+i)))
+		}
+		err := wb.Commit()
+		if budget >= 0 {
+			assert.Equal(t, fio.ErrInjectedFault, err)
+		} else {
+			assert.Nil(t, err)
+		}
+
+		db = crashAndReopen(t, db, fi)
+
+		for i := 0; i < 50; i++ {
+			value, err := db.Get(utils.GetTestKey(round*100 + i))
+			if budget >= 0 {
+				assert.Equal(t, ErrKeyNotFound, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, crashTestValue(round*100+i), value)
+			}
+		}
+	}
+	destroyDB(db)
+}
+
+func TestDB_CrashMerge(t *testing.T) {
+	db, fi := openCrashTestDB(t, "bitcask-go-crash-merge", false)
+
+	for i := 0; i < 2000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), crashTestValue(i)))
+	}
+	for i := 0; i < 2000; i += 2 {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	assert.Nil(t, db.Sync())
+
+	check := func(db *DB) {
+		for i := 0; i < 2000; i++ {
+			value, err := db.Get(utils.GetTestKey(i))
+			if i%2 == 0 {
+				assert.Equal(t, ErrKeyNotFound, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, crashTestValue(i), value)
+			}
+		}
+	}
+
+	// merge fails halfway
+	fi.FailWritesAfter(40 * 1024)
+	assert.NotNil(t, db.Merge())
+	db = crashAndReopen(t, db, fi)
+	check(db)
+
+	// crash right after merge
+	assert.Nil(t, db.Merge())
+	db = crashAndReopen(t, db, fi)
+	check(db)
+
+	// reopen after merge files are installed by load
+	assert.Nil(t, db.Close())
+	db, err := OpenDB(db.options)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	check(db)
+}
+
+func TestDB_FaultReads(t *testing.T) {
+	db, fi := openCrashTestDB(t, "bitcask-go-fault-reads", false)
+	defer destroyDB(db)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), crashTestValue(i)))
+	}
+
+	// faults are returned, never a wrong value
+	fi.CorruptReads(true)
+	value, err := db.Get(utils.GetTestKey(10))
+	assert.NotNil(t, err)
+	assert.Nil(t, value)
+
+	fi.Reset()
+	fi.ShortReads(true)
+	value, err = db.Get(utils.GetTestKey(10))
+	assert.NotNil(t, err)
+	assert.Nil(t, value)
+
+	fi.Reset()
+	value, err = db.Get(utils.GetTestKey(10))
+	assert.Nil(t, err)
+	assert.Equal(t, crashTestValue(10), value)
+}
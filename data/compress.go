@@ -0,0 +1,49 @@
+package data
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+)
+
+// flate writer allocates large buffers, so writers and readers are reused
+var (
+	flateWriterPool = sync.Pool{
+		New: func() interface{} {
+			w, _ := flate.NewWriter(nil, flate.BestSpeed)
+			return w
+		},
+	}
+	flateReaderPool = sync.Pool{
+		New: func() interface{} {
+			return flate.NewReader(nil)
+		},
+	}
+)
+
+// compress value by flate
+func CompressValue(value []byte) []byte {
+	var buf bytes.Buffer
+	w := flateWriterPool.Get().(*flate.Writer)
+	defer flateWriterPool.Put(w)
+
+	// writing to bytes.Buffer never fails
+	w.Reset(&buf)
+	_, _ = w.Write(value)
+	_ = w.Close()
+
+	return buf.Bytes()
+}
+
+// decompress value compressed by CompressValue
+func DecompressValue(value []byte) ([]byte, error) {
+	r := flateReaderPool.Get().(io.ReadCloser)
+	defer flateReaderPool.Put(r)
+
+	if err := r.(flate.Resetter).Reset(bytes.NewReader(value), nil); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
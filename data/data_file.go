@@ -2,6 +2,7 @@ package data
 
 import (
 	"bitcask-go/fio"
+	"crypto/cipher"
 	"errors"
 	"fmt"
 	"io"
@@ -28,35 +29,48 @@ type DataFile struct {
 	FileId    uint32
 	WriteOff  int64
 	IoManager fio.IOManager
+
+	Version    byte   // version of file header, 0 if file has no header
+	CreateTime int64  // unix nano time when file is created, 0 if unknown
+	HeaderSize int64  // log records start after file header
+	KeyId      uint32 // id of encrypt key if file is encrypted
+	aead       cipher.AEAD
 }
 
 // open or create file in dirpath with fid,
 //  full path is format as /dirpath/xxx.data, xxx is fid
-func OpenDataFile(dirPath string, fid uint32, iotype fio.FileIOType) (*DataFile, error) {
+// keyring is used to read encrypted file, it can be nil if file is plaintext
+func OpenDataFile(dirPath string, fid uint32, iotype fio.FileIOType, keyring *Keyring) (*DataFile, error) {
 	filename := filepath.Join(dirPath, fmt.Sprintf("%09d", fid)+DataFileSuffix)
-	return newDataFile(filename, fid, iotype)
+	return newDataFile(filename, fid, iotype, keyring)
 }
 
 // Open Hint file, Hint file is consist of all records in index
-func OpenHintFile(dirPath string) (*DataFile, error) {
+func OpenHintFile(dirPath string, iotype fio.FileIOType, keyring *Keyring) (*DataFile, error) {
 	filename := filepath.Join(dirPath, HintFileName)
-	return newDataFile(filename, 0, fio.StandFileIO)
+	return newDataFile(filename, 0, iotype, keyring)
 }
 
 // Merge Finish File exist present merging complete
-func OpenMergeFinFile(dirPath string) (*DataFile, error) {
+func OpenMergeFinFile(dirPath string, iotype fio.FileIOType) (*DataFile, error) {
 	fileName := filepath.Join(dirPath, HintFinFileName)
-	return newDataFile(fileName, 0, fio.StandFileIO)
+	return newDataFile(fileName, 0, iotype, nil)
 }
 
-func newDataFile(fileName string, fid uint32, iotype fio.FileIOType) (*DataFile, error) {
+func newDataFile(fileName string, fid uint32, iotype fio.FileIOType, keyring *Keyring) (*DataFile, error) {
 	ioManager, err := fio.NewIOManager(fileName, iotype)
-	size, _ := ioManager.Size()
 	if err != nil {
 		return nil, err
 	}
+	size, _ := ioManager.Size()
 
-	return &DataFile{FileId: fid, WriteOff: size, IoManager: ioManager}, nil
+	dataFile := &DataFile{FileId: fid, WriteOff: size, IoManager: ioManager}
+	if err := dataFile.loadFileHeader(keyring); err != nil {
+		_ = ioManager.Close()
+		return nil, err
+	}
+
+	return dataFile, nil
 }
 
 // format filename as dirPath/fid.data
@@ -72,18 +86,34 @@ func (df *DataFile) WriteHintRecord(key []byte, pos *LogRecordPos) error {
 		Value: EncodeLogRecordPos(pos), // encode position as binary sequence
 	}
 
-	encRecord, _ := EncodeLogRecord(hintRecord)
+	encRecord, _ := df.EncodeLogRecord(hintRecord)
 
 	return df.Write(encRecord)
 }
 
-// read logRecord from disk datafile at offset
+// encode log record to be written to this file,
+// key and value are encrypted if file is encrypted
+func (df *DataFile) EncodeLogRecord(logRecord *LogRecord) ([]byte, int64) {
+	if df.aead == nil {
+		return EncodeLogRecord(logRecord)
+	}
+
+	return EncodeLogRecord(sealLogRecord(df.aead, logRecord))
+}
+
+// read logRecord from disk datafile at offset, compressed value is decompressed
+// return io.EOF if no record at offset, io.ErrUnexpectedEOF if record is incomplete,
+// size of record is returned with ErrInvalidCRC
 func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 	fileSize, err := df.IoManager.Size()
 	if err != nil {
 		return nil, 0, err
 	}
 
+	if offset >= fileSize {
+		return nil, 0, io.EOF
+	}
+
 	var headerBytes int64 = LogRecordHeaderSize
 
 	// check some special case
@@ -103,6 +133,9 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 
 	// return error EOF if header is empty or no logEntry at offset
 	if header == nil {
+		if headerBytes > 0 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
 		return nil, 0, io.EOF
 	}
 
@@ -111,9 +144,14 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 	}
 
 	// read Log record
-	var kvSize int64 = int64(header.keySize + header.valSize)
+	var kvSize int64 = int64(header.keySize) + int64(header.valSize)
 	logRecordSize := headerSize + kvSize
-	logRecord := &LogRecord{Type: header.recordType}
+	logRecord := &LogRecord{Type: header.recordType, Expire: header.expire}
+
+	// record is partially written
+	if offset+logRecordSize > fileSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
 
 	if kvSize > 0 {
 		kvBuf, err := df.ReadNBytes(kvSize, offset+headerSize)
@@ -124,26 +162,129 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 		logRecord.Value = kvBuf[header.keySize:]
 	}
 
+	if err := df.checkAndOpenLogRecord(logRecord, header, headerBuf, offset); err != nil {
+		if err == ErrInvalidCRC {
+			return nil, logRecordSize, err
+		}
+		return nil, 0, err
+	}
+
+	return logRecord, int64(logRecordSize), nil
+}
+
+// decode log record from buf which is read from this file at offset,
+// buf must contain the whole record, it's used to decode records read by one io
+func (df *DataFile) DecodeLogRecord(buf []byte, offset int64) (*LogRecord, int64, error) {
+	header, headerSize := DecodeLogRecordHeader(buf)
+	if header == nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	logRecordSize := headerSize + int64(header.keySize) + int64(header.valSize)
+	if logRecordSize > int64(len(buf)) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	logRecord := &LogRecord{
+		Key:    buf[headerSize : headerSize+int64(header.keySize)],
+		Value:  buf[headerSize+int64(header.keySize) : logRecordSize],
+		Type:   header.recordType,
+		Expire: header.expire,
+	}
+
+	if err := df.checkAndOpenLogRecord(logRecord, header, buf[:headerSize], offset); err != nil {
+		if err == ErrInvalidCRC {
+			return nil, logRecordSize, err
+		}
+		return nil, 0, err
+	}
+
+	return logRecord, logRecordSize, nil
+}
+
+// return true if a complete record with valid crc starts after offset,
+// it tells corruption in the middle of file from a torn record at the end
+func (df *DataFile) HasRecordAfter(offset int64) (bool, error) {
+	fileSize, err := df.IoManager.Size()
+	if err != nil {
+		return false, err
+	}
+	if offset+1 >= fileSize {
+		return false, nil
+	}
+
+	buf, err := df.ReadNBytes(fileSize-offset-1, offset+1)
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < len(buf); i++ {
+		header, headerSize := DecodeLogRecordHeader(buf[i:])
+		if header == nil {
+			continue
+		}
+		recordSize := headerSize + int64(header.keySize) + int64(header.valSize)
+		if recordSize > int64(len(buf)-i) {
+			continue
+		}
+
+		record := &LogRecord{
+			Key:   buf[int64(i)+headerSize : int64(i)+headerSize+int64(header.keySize)],
+			Value: buf[int64(i)+headerSize+int64(header.keySize) : int64(i)+recordSize],
+		}
+		if getRecordCRC(record, buf[i:int64(i)+headerSize]) == header.crc {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// check crc of record, then decrypt and decompress it in place
+func (df *DataFile) checkAndOpenLogRecord(logRecord *LogRecord, header *LogRecordHeader, headerBuf []byte, offset int64) error {
 	// check CRC
 	crc := getRecordCRC(logRecord, headerBuf)
 	if crc != header.crc {
 		logrus.Errorf("crc checking code doesn't match at file %v offset %v", df.FileId, offset)
-		return nil, 0, ErrInvalidCRC
+		return ErrInvalidCRC
 	}
 
-	return logRecord, int64(logRecordSize), nil
+	// key and value are returned in plaintext
+	if df.aead != nil {
+		if err := openLogRecord(df.aead, logRecord); err != nil {
+			return err
+		}
+	}
+
+	// value is returned uncompressed
+	if header.compressed {
+		value, err := DecompressValue(logRecord.Value)
+		if err != nil {
+			return err
+		}
+		logRecord.Value = value
+	}
+
+	return nil
 }
 
 func (df *DataFile) Read(buffer []byte, offset int64) (int, error) {
 	return df.IoManager.Read(buffer, offset)
 }
 
+// bytes of a partially failed write are dropped, so next record follows the
+// last complete one. if they can't be dropped, later records are written after them
 func (df *DataFile) Write(data []byte) error {
 	n, err := df.IoManager.Write(data)
-	if err != nil {
+	if err == nil || n == 0 {
+		df.WriteOff += int64(n)
 		return err
 	}
-	df.WriteOff += int64(n)
+
+	if truncErr := df.IoManager.Truncate(df.WriteOff); truncErr != nil {
+		logrus.Errorf("failed to drop partial write of file %v at offset %v: %v", df.FileId, df.WriteOff, truncErr)
+		df.WriteOff += int64(n)
+	}
 	return err
 }
 
@@ -2,6 +2,8 @@ package data
 
 import (
 	"bitcask-go/fio"
+	"bytes"
+	"io"
 	"os"
 	"testing"
 
@@ -9,21 +11,27 @@ import (
 )
 
 func TestOpenDataFile(t *testing.T) {
-	dataFile1, err := OpenDataFile(os.TempDir(), 0, fio.StandFileIO)
+	dir, _ := os.MkdirTemp("", "bitcask-go-datafile")
+	defer os.RemoveAll(dir)
+
+	dataFile1, err := OpenDataFile(dir, 0, fio.StandFileIO, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile1)
+	defer dataFile1.Close()
 
-	dataFile2, err := OpenDataFile(os.TempDir(), 111, fio.StandFileIO)
+	dataFile2, err := OpenDataFile(dir, 111, fio.StandFileIO, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile2)
+	defer dataFile2.Close()
 
-	dataFile3, err := OpenDataFile(os.TempDir(), 111, fio.StandFileIO)
+	dataFile3, err := OpenDataFile(dir, 111, fio.StandFileIO, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile3)
+	defer dataFile3.Close()
 }
 
 func TestDataFile_Write(t *testing.T) {
-	dataFile, err := OpenDataFile(os.TempDir(), 0, fio.StandFileIO)
+	dataFile, err := OpenDataFile("", 0, fio.MemoryIO, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
 
@@ -35,10 +43,14 @@ func TestDataFile_Write(t *testing.T) {
 
 	err = dataFile.Write([]byte("ccc"))
 	assert.Nil(t, err)
+	assert.Equal(t, int64(9), dataFile.WriteOff)
 }
 
 func TestDataFile_Close(t *testing.T) {
-	dataFile, err := OpenDataFile(os.TempDir(), 123, fio.StandFileIO)
+	dir, _ := os.MkdirTemp("", "bitcask-go-datafile")
+	defer os.RemoveAll(dir)
+
+	dataFile, err := OpenDataFile(dir, 123, fio.StandFileIO, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
 
@@ -50,9 +62,13 @@ func TestDataFile_Close(t *testing.T) {
 }
 
 func TestDataFile_Sync(t *testing.T) {
-	dataFile, err := OpenDataFile(os.TempDir(), 456, fio.StandFileIO)
+	dir, _ := os.MkdirTemp("", "bitcask-go-datafile")
+	defer os.RemoveAll(dir)
+
+	dataFile, err := OpenDataFile(dir, 456, fio.StandFileIO, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
+	defer dataFile.Close()
 
 	err = dataFile.Write([]byte("aaa"))
 	assert.Nil(t, err)
@@ -60,3 +76,101 @@ func TestDataFile_Sync(t *testing.T) {
 	err = dataFile.Sync()
 	assert.Nil(t, err)
 }
+
+func TestDataFile_ReadPartialLogRecord(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-datafile")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandFileIO, nil)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+
+	encRecord, size := EncodeLogRecord(&LogRecord{Key: []byte("name"), Value: []byte("bitcask-go")})
+	err = dataFile.Write(encRecord)
+	assert.Nil(t, err)
+	err = dataFile.Write(encRecord[:size-3])
+	assert.Nil(t, err)
+
+	record, readSize, err := dataFile.ReadLogRecord(0)
+	assert.Nil(t, err)
+	assert.Equal(t, size, readSize)
+	assert.Equal(t, []byte("bitcask-go"), record.Value)
+
+	_, _, err = dataFile.ReadLogRecord(size)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDataFile_ReadCompressedLogRecord(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-datafile")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandFileIO, nil)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+
+	value := bytes.Repeat([]byte(`{"name":"bitcask-go"}`), 100)
+	compressed := CompressValue(value)
+	assert.Less(t, len(compressed), len(value))
+
+	encRecord, size := EncodeLogRecord(&LogRecord{Key: []byte("name"), Value: compressed, Compressed: true})
+	err = dataFile.Write(encRecord)
+	assert.Nil(t, err)
+
+	record, readSize, err := dataFile.ReadLogRecord(0)
+	assert.Nil(t, err)
+	assert.Equal(t, size, readSize)
+	assert.Equal(t, value, record.Value)
+}
+
+func TestDataFile_FileHeader(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-datafile")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 1, fio.StandFileIO, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, dataFile.InitFileHeader(nil))
+
+	encRecord, size := EncodeLogRecord(&LogRecord{Key: []byte("name"), Value: []byte("bitcask-go")})
+	assert.Nil(t, dataFile.Write(encRecord))
+	assert.Nil(t, dataFile.Close())
+
+	dataFile, err = OpenDataFile(dir, 1, fio.StandFileIO, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, FileHeaderVersion, dataFile.Version)
+	assert.Equal(t, int64(FileHeaderSize), dataFile.HeaderSize)
+	assert.Greater(t, dataFile.CreateTime, int64(0))
+
+	record, readSize, err := dataFile.ReadLogRecord(dataFile.HeaderSize)
+	assert.Nil(t, err)
+	assert.Equal(t, size, readSize)
+	assert.Equal(t, []byte("bitcask-go"), record.Value)
+	assert.Nil(t, dataFile.Close())
+
+	// file is renamed to another file id
+	assert.Nil(t, os.Rename(GetDataFileName(dir, 1), GetDataFileName(dir, 2)))
+	_, err = OpenDataFile(dir, 2, fio.StandFileIO, nil)
+	assert.Equal(t, ErrFileIdMismatch, err)
+
+	// header is corrupted
+	buf, err := os.ReadFile(GetDataFileName(dir, 2))
+	assert.Nil(t, err)
+	buf[12] ^= 0xff
+	assert.Nil(t, os.WriteFile(GetDataFileName(dir, 3), buf, 0644))
+	_, err = OpenDataFile(dir, 3, fio.StandFileIO, nil)
+	assert.Equal(t, ErrInvalidFileHeader, err)
+
+	// header of unknown version
+	buf[4] = FileHeaderVersion + 1
+	assert.Nil(t, os.WriteFile(GetDataFileName(dir, 4), buf, 0644))
+	_, err = OpenDataFile(dir, 4, fio.StandFileIO, nil)
+	assert.Equal(t, ErrUnsupportedFileVersion, err)
+
+	// file written by old version has no header
+	assert.Nil(t, os.WriteFile(GetDataFileName(dir, 5), encRecord, 0644))
+	dataFile, err = OpenDataFile(dir, 5, fio.StandFileIO, nil)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+	assert.Equal(t, byte(0), dataFile.Version)
+	assert.Equal(t, int64(0), dataFile.HeaderSize)
+
+	record, _, err = dataFile.ReadLogRecord(0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("bitcask-go"), record.Value)
+}
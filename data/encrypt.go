@@ -0,0 +1,81 @@
+package data
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrEncryptKeyNotFound = errors.New("encrypt key of file is not found")
+	ErrDecryptFailed      = errors.New("failed to decrypt log record")
+)
+
+// Keyring holds keys of at-rest encryption, new files are encrypted by key of
+// CurrentId, files encrypted by other keys in Keys can still be read
+type Keyring struct {
+	CurrentId uint32
+	Keys      map[uint32][]byte // AES-128, AES-192 or AES-256 key
+}
+
+// create AES-GCM cipher with key of keyId
+func (keyring *Keyring) newAEAD(keyId uint32) (cipher.AEAD, error) {
+	if keyring == nil {
+		return nil, ErrEncryptKeyNotFound
+	}
+	key, ok := keyring.Keys[keyId]
+	if !ok {
+		return nil, ErrEncryptKeyNotFound
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encrypt key and value of record as a single payload,
+// nonce + seal(keysize + key + value) is stored as key of encrypted record
+func sealLogRecord(aead cipher.AEAD, logRecord *LogRecord) *LogRecord {
+	plain := make([]byte, binary.MaxVarintLen32+len(logRecord.Key)+len(logRecord.Value))
+	index := binary.PutUvarint(plain, uint64(len(logRecord.Key)))
+	index += copy(plain[index:], logRecord.Key)
+	index += copy(plain[index:], logRecord.Value)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic("failed to generate nonce for encryption")
+	}
+
+	record := *logRecord
+	record.Key = aead.Seal(nonce, nonce, plain[:index], nil)
+	record.Value = nil
+	return &record
+}
+
+// decrypt record encrypted by sealLogRecord in place
+func openLogRecord(aead cipher.AEAD, logRecord *LogRecord) error {
+	nonceSize := aead.NonceSize()
+	if len(logRecord.Key) < nonceSize {
+		return ErrDecryptFailed
+	}
+
+	nonce, sealed := logRecord.Key[:nonceSize], logRecord.Key[nonceSize:]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return ErrDecryptFailed
+	}
+
+	keySize, n := binary.Uvarint(plain)
+	if n <= 0 || uint64(len(plain)-n) < keySize {
+		return ErrDecryptFailed
+	}
+
+	logRecord.Key = plain[n : n+int(keySize)]
+	logRecord.Value = plain[n+int(keySize):]
+	return nil
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"time"
+)
+
+// ---- header at the beginning of data file and hint file ----
+//
+// magic(4) + version(1) + flags(1) + fileId(4) + ctime(8) + keyId(4) + crc(4)
+// files without header are plaintext files written by old version
+//
+const (
+	fileHeaderMagic   uint32 = 0x4b534342 // "BCSK" in little endian
+	FileHeaderVersion byte   = 2
+	FileHeaderSize           = 26
+)
+
+// flags of file header
+const (
+	fileHeaderEncryptFlag byte = 1 << 0 // log records are encrypted by key of keyId
+)
+
+var (
+	ErrUnsupportedFileVersion = errors.New("unsupported version of file header")
+	ErrInvalidFileHeader      = errors.New("invalid file header")
+	ErrFileIdMismatch         = errors.New("file id in header doesn't match file name")
+)
+
+// write header to empty file, file is encrypted by current key of keyring
+// if keyring is not nil, nothing to do if file is not empty
+func (df *DataFile) InitFileHeader(keyring *Keyring) error {
+	if df.WriteOff != 0 {
+		return nil
+	}
+
+	var flags byte
+	var keyId uint32
+	if keyring != nil {
+		aead, err := keyring.newAEAD(keyring.CurrentId)
+		if err != nil {
+			return err
+		}
+		flags |= fileHeaderEncryptFlag
+		keyId = keyring.CurrentId
+		df.aead = aead
+	}
+
+	ctime := time.Now().UnixNano()
+
+	buf := make([]byte, FileHeaderSize)
+	binary.LittleEndian.PutUint32(buf[:4], fileHeaderMagic)
+	buf[4] = FileHeaderVersion
+	buf[5] = flags
+	binary.LittleEndian.PutUint32(buf[6:10], df.FileId)
+	binary.LittleEndian.PutUint64(buf[10:18], uint64(ctime))
+	binary.LittleEndian.PutUint32(buf[18:22], keyId)
+	binary.LittleEndian.PutUint32(buf[22:], crc32.ChecksumIEEE(buf[:22]))
+
+	if err := df.Write(buf); err != nil {
+		df.aead = nil
+		return err
+	}
+
+	df.Version = FileHeaderVersion
+	df.CreateTime = ctime
+	df.KeyId = keyId
+	df.HeaderSize = FileHeaderSize
+
+	return nil
+}
+
+// return true if records appended to file are encrypted by current key
+// of keyring, or are plaintext if keyring is nil
+func (df *DataFile) MatchKeyring(keyring *Keyring) bool {
+	if keyring == nil {
+		return df.aead == nil
+	}
+	return df.aead != nil && df.KeyId == keyring.CurrentId
+}
+
+// read and validate header of file, set cipher of file if it's encrypted,
+// file without magic is treated as headerless file
+func (df *DataFile) loadFileHeader(keyring *Keyring) error {
+	if df.WriteOff < 5 {
+		return nil
+	}
+
+	buf, err := df.ReadNBytes(5, 0)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(buf[:4]) != fileHeaderMagic {
+		return nil
+	}
+
+	if buf[4] != FileHeaderVersion {
+		return ErrUnsupportedFileVersion
+	}
+	if df.WriteOff < FileHeaderSize {
+		return ErrInvalidFileHeader
+	}
+
+	buf, err = df.ReadNBytes(FileHeaderSize, 0)
+	if err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(buf[:22]) != binary.LittleEndian.Uint32(buf[22:]) {
+		return ErrInvalidFileHeader
+	}
+	if binary.LittleEndian.Uint32(buf[6:10]) != df.FileId {
+		return ErrFileIdMismatch
+	}
+
+	df.Version = FileHeaderVersion
+	df.CreateTime = int64(binary.LittleEndian.Uint64(buf[10:18]))
+	df.HeaderSize = FileHeaderSize
+
+	if buf[5]&fileHeaderEncryptFlag != 0 {
+		df.KeyId = binary.LittleEndian.Uint32(buf[18:22])
+		df.aead, err = keyring.newAEAD(df.KeyId)
+	}
+
+	return err
+}
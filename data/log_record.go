@@ -3,35 +3,50 @@ package data
 import (
 	"encoding/binary"
 	"hash/crc32"
+	"time"
 )
 
 type LogRecordType = byte
 
-// crc: 4byte, type: 1 byte, keysize and value size: 5(2^5 = 32)
-const LogRecordHeaderSize = binary.MaxVarintLen32*2 + 5
+// crc: 4byte, type: 1 byte, keysize and value size: 5(2^5 = 32), expire: 10
+const LogRecordHeaderSize = binary.MaxVarintLen32*2 + binary.MaxVarintLen64 + 5
 
 const (
 	LogRecordNormal LogRecordType = iota
 	LogRecordDelete
 	LogRecordTxnFin
+	LogRecordRangeDelete // delete keys in [key, value), empty value means no upper bound
+)
+
+// high bits of type byte are flags of log record,
+// records written by old version have no flag
+const (
+	logRecordTypeMask     byte = 0x0f
+	logRecordExpireFlag   byte = 1 << 7 // expire is stored after value size
+	logRecordCompressFlag byte = 1 << 6 // value is compressed by flate
 )
 
 // log record header
-// crc + type + keysize + valuesize
-// keysize and value size store to disk as varint
+// crc + type + keysize + valuesize + [expire]
+// keysize, value size and expire store to disk as varint
 type LogRecordHeader struct {
 	crc        uint32
 	recordType LogRecordType
 	keySize    uint32
 	valSize    uint32
+	expire     int64
+	compressed bool
 }
 
 // log record content
 // key + value + type
 type LogRecord struct {
-	Key   []byte
-	Value []byte
-	Type  LogRecordType
+	Key    []byte
+	Value  []byte
+	Type   LogRecordType
+	Expire int64 // unix nano time, 0 if never expire
+
+	Compressed bool // value is compressed, only used for encoding
 }
 
 // keydir value in memory
@@ -39,6 +54,12 @@ type LogRecordPos struct {
 	FileId uint32
 	Size   uint32
 	Offset int64
+	Expire int64 // unix nano time, 0 if never expire
+}
+
+// return true if record at pos has expired
+func (pos *LogRecordPos) Expired() bool {
+	return pos.Expire != 0 && pos.Expire <= time.Now().UnixNano()
 }
 
 //
@@ -55,11 +76,20 @@ func EncodeLogRecord(logRecord *LogRecord) ([]byte, int64) {
 	var index = crcSize
 
 	header[index] = logRecord.Type
+	if logRecord.Expire != 0 {
+		header[index] |= logRecordExpireFlag
+	}
+	if logRecord.Compressed {
+		header[index] |= logRecordCompressFlag
+	}
 	index += 1
 
 	// use varint to save storage space
 	index += binary.PutUvarint(header[index:], uint64(len(logRecord.Key)))
 	index += binary.PutUvarint(header[index:], uint64(len(logRecord.Value)))
+	if logRecord.Expire != 0 {
+		index += binary.PutVarint(header[index:], logRecord.Expire)
+	}
 
 	var size = index + len(logRecord.Key) + len(logRecord.Value)
 	encRecord := make([]byte, size)
@@ -87,17 +117,33 @@ func DecodeLogRecordHeader(buf []byte) (*LogRecordHeader, int64) {
 
 	header := &LogRecordHeader{
 		crc:        binary.LittleEndian.Uint32(buf[:4]),
-		recordType: buf[4],
+		recordType: buf[4] & logRecordTypeMask,
+		compressed: buf[4]&logRecordCompressFlag != 0,
 	}
 	index := 5
 	keySize, n := binary.Uvarint(buf[index:])
+	if n <= 0 {
+		return nil, 0
+	}
 	header.keySize = uint32(keySize)
 	index += n
 
 	valSize, m := binary.Uvarint(buf[index:])
+	if m <= 0 {
+		return nil, 0
+	}
 	header.valSize = uint32(valSize)
 	index += m
 
+	if buf[4]&logRecordExpireFlag != 0 {
+		expire, k := binary.Varint(buf[index:])
+		if k <= 0 {
+			return nil, 0
+		}
+		header.expire = expire
+		index += k
+	}
+
 	return header, int64(index)
 }
 
@@ -114,13 +160,16 @@ func getRecordCRC(log *LogRecord, header []byte) uint32 {
 	return crc
 }
 
-// encord logrecordPos to bytes
+// encord logrecordPos to bytes, expire is omitted if key never expire
 func EncodeLogRecordPos(pos *LogRecordPos) []byte {
-	buf := make([]byte, binary.MaxVarintLen32+binary.MaxVarintLen64)
+	buf := make([]byte, binary.MaxVarintLen32*2+binary.MaxVarintLen64*2)
 	var index = 0
 	index += binary.PutUvarint(buf[index:], uint64(pos.FileId))
 	index += binary.PutUvarint(buf[index:], uint64(pos.Size))
 	index += binary.PutVarint(buf[index:], pos.Offset)
+	if pos.Expire != 0 {
+		index += binary.PutVarint(buf[index:], pos.Expire)
+	}
 
 	return buf[:index]
 }
@@ -129,13 +178,20 @@ func DecodeLogRecordPos(buf []byte) *LogRecordPos {
 	var index = 0
 	fileId, n := binary.Uvarint(buf[index:])
 	index += n
-	size, _ := binary.Uvarint(buf[index:])
+	size, n := binary.Uvarint(buf[index:])
+	index += n
+	offset, n := binary.Varint(buf[index:])
 	index += n
-	offset, _ := binary.Varint(buf[index:])
+
+	var expire int64
+	if index < len(buf) {
+		expire, _ = binary.Varint(buf[index:])
+	}
 
 	return &LogRecordPos{
 		FileId: uint32(fileId),
 		Size:   uint32(size),
 		Offset: offset,
+		Expire: expire,
 	}
 }
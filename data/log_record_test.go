@@ -49,3 +49,29 @@ func TestDecodeLogRecordHeader(t *testing.T) {
 	assert.Equal(t, uint32(8), h3.keySize)
 	assert.Equal(t, uint32(20), h3.valSize)
 }
+
+func TestEncodeLogRecordPos(t *testing.T) {
+	pos := &LogRecordPos{FileId: 1, Size: 1040, Offset: 11500586}
+	decPos := DecodeLogRecordPos(EncodeLogRecordPos(pos))
+	assert.Equal(t, pos, decPos)
+}
+
+func TestEncodeLogRecord_Expire(t *testing.T) {
+	log := &LogRecord{
+		Key:    []byte("default-key"),
+		Value:  []byte("default-value"),
+		Type:   LogRecordNormal,
+		Expire: 1700000000000000000,
+	}
+
+	encLog, size := EncodeLogRecord(log)
+	header, headerSize := DecodeLogRecordHeader(encLog)
+	assert.Equal(t, LogRecordNormal, header.recordType)
+	assert.Equal(t, log.Expire, header.expire)
+	assert.Equal(t, uint32(len(log.Key)), header.keySize)
+	assert.Equal(t, uint32(len(log.Value)), header.valSize)
+	assert.Equal(t, size, headerSize+int64(len(log.Key)+len(log.Value)))
+
+	pos := &LogRecordPos{FileId: 1, Size: 40, Offset: 100, Expire: log.Expire}
+	assert.Equal(t, pos, DecodeLogRecordPos(EncodeLogRecordPos(pos)))
+}
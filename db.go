@@ -6,6 +6,7 @@ import (
 	"bitcask-go/index"
 	"bitcask-go/utils"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gofrs/flock"
 	"github.com/sirupsen/logrus"
@@ -42,6 +44,21 @@ type DB struct {
 
 	bytesWrite  uint64 // bytes has been write
 	reclaimSize int64  // unvalid bytes has been write
+
+	snapshots    map[*Snapshot]struct{} // snapshots haven't been released
+	retiredFiles []*data.DataFile       // files replaced by merge but referenced by snapshots
+	checkpoint   *indexCheckpoint       // replay log records from here if keydir is persistent
+	keyring      *data.Keyring          // keys of encryption, nil if not encrypted
+	groupCommit  *groupCommit           // shared fsync of synchronous writes
+	valueCache   *valueCache            // nil if value cache is disabled
+	syncErr      error                  // fsync of synchronous writes failed, reads and writes are rejected
+
+	autoMergeStop chan struct{} // close it to stop auto merge
+	autoMergeWg   *sync.WaitGroup
+
+	// read-only db replays records appended by writer at refresh
+	pendingTxns map[uint64][]*data.TransactionRecord // transactions without fin record
+	nonMergeFid uint32                               // merged files found at load
 }
 
 type Stat struct {
@@ -49,6 +66,8 @@ type Stat struct {
 	DataFileNum uint
 	ReclaimSize int64
 	DiskSize    int64
+	CacheHits   uint64 // reads served by value cache
+	CacheMisses uint64 // reads missed value cache
 }
 
 // return statistic info about db
@@ -61,96 +80,236 @@ func (db *DB) Stat() *Stat {
 		dataFiles += 1
 	}
 
-	return &Stat{
-		KeyNum:      uint(db.index.Size()),
+	stat := &Stat{
+		KeyNum:      uint(db.keyNum()),
 		DataFileNum: dataFiles,
 		ReclaimSize: db.reclaimSize,
 		DiskSize:    0,
 	}
+	if db.valueCache != nil {
+		stat.CacheHits, stat.CacheMisses = db.valueCache.stat()
+	}
+
+	return stat
 
 }
 
+// number of keys in index, it's only used as a hint,
+// so error of keydir persisted on disk is logged and 0 is returned
+func (db *DB) keyNum() int {
+	size, err := db.index.Size()
+	if err != nil {
+		logrus.Errorf("[Bitcask] failed to count keys in index of %v: %v", db.options.DirPath, err)
+	}
+	return size
+}
+
 // open bitcask db
 func OpenDB(options Options) (*DB, error) {
 	if err := checkOptions(options); err != nil {
 		return nil, err
 	}
 
+	if options.InMemory {
+		return openInMemoryDB(options)
+	}
+
 	var isInitial bool
 
 	if _, err := os.Stat(options.DirPath); os.IsNotExist(err) {
+		if options.ReadOnly {
+			return nil, err
+		}
 		isInitial = true
 		if err := os.Mkdir(options.DirPath, os.ModePerm); err != nil {
 			return nil, err
 		}
 	}
 
-	filelock := flock.New(filepath.Join(options.DirPath, fileLockName))
-	hold, err := filelock.TryLock()
-	if err != nil {
-		return nil, err
-	}
+	// readers take no lock, they never block writer
+	var filelock *flock.Flock
+	if !options.ReadOnly {
+		filelock = flock.New(filepath.Join(options.DirPath, fileLockName))
+		hold, err := filelock.TryLock()
+		if err != nil {
+			return nil, err
+		}
 
-	if !hold {
-		return nil, ErrDataBaseIsUsing
+		if !hold {
+			return nil, ErrDataBaseIsUsing
+		}
 	}
 
 	db := &DB{
 		options:    options,
 		mu:         new(sync.RWMutex),
-		index:      index.NewIndexer(options.Index),
 		olderFiles: make(map[uint32]*data.DataFile),
+		snapshots:  make(map[*Snapshot]struct{}),
 		isInitial:  isInitial,
 		filelock:   filelock,
+		keyring:    newKeyring(options),
+		valueCache: newValueCache(options.ValueCacheSize),
 	}
+	db.groupCommit = newGroupCommit()
 
-	// load merge file to work directory
-	if err := db.loaderMergeFiles(); err != nil {
+	if err := db.load(); err != nil {
+		db.closeOnLoadFailed()
 		return nil, err
 	}
+
+	if db.options.AutoMergeInterval > 0 && !db.options.ReadOnly {
+		db.startAutoMerge()
+	}
+
+	logrus.Infof("[Bitcask] OpenDB at %v, total entries: %v\n",
+		options.DirPath, db.keyNum())
+
+	return db, nil
+}
+
+// open db without dir and file lock, active file is created by first write
+func openInMemoryDB(options Options) (*DB, error) {
+	db := &DB{
+		options:    options,
+		mu:         new(sync.RWMutex),
+		olderFiles: make(map[uint32]*data.DataFile),
+		snapshots:  make(map[*Snapshot]struct{}),
+		isInitial:  true,
+		keyring:    newKeyring(options),
+		valueCache: newValueCache(options.ValueCacheSize),
+	}
+	db.groupCommit = newGroupCommit()
+
+	if err := db.openIndex(false); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// load merge files, data files and keydir from db dir
+func (db *DB) load() error {
+	// load merge file to work directory, read-only db reads files in dir as they are
+	var merged bool
+	if !db.options.ReadOnly {
+		var err error
+		if merged, err = db.loaderMergeFiles(); err != nil {
+			return err
+		}
+	}
+
+	// create keydir, it must be created after merge files loaded
+	if err := db.openIndex(merged); err != nil {
+		return err
+	}
+
 	// load datafile
 	if err := db.loadDataFile(); err != nil {
-		return nil, err
+		return err
 	}
 
-	// load index info from hint file
-	if err := db.loadIndexFromHintFile(); err != nil {
-		return nil, err
+	if err := db.checkIndexCheckpoint(); err != nil {
+		return err
+	}
+
+	// load index info from hint file, persistent keydir with checkpoint has contained it
+	if db.checkpoint == nil {
+		if err := db.loadIndexFromHintFile(); err != nil {
+			return err
+		}
 	}
 
 	// create index from data file
 	if err := db.loadIndexFromDateFile(); err != nil {
-		return nil, err
+		return err
 	}
 
-	if db.options.MMapAtStartup {
+	if db.startupIOType() != db.fileIOType() {
 		if err := db.resetIoType(); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	logrus.Infof("[Bitcask] OpenDB at %v, total entries: %v\n",
-		options.DirPath, db.index.Size())
+	// active file may be created without header before crash
+	if db.activeFile != nil && !db.options.ReadOnly {
+		if err := db.activeFile.InitFileHeader(db.keyring); err != nil {
+			return err
+		}
+		// encryption is enabled or key is rotated, new records go to a new file
+		if !db.activeFile.MatchKeyring(db.keyring) {
+			if err := db.setActiveDataFile(); err != nil {
+				return err
+			}
+		}
+	}
 
-	return db, nil
+	// checkpoint is stale once keydir is updated
+	return db.removeIndexCheckpoint()
+}
+
+// release resources opened by load, so db can be opened again
+func (db *DB) closeOnLoadFailed() {
+	if db.index != nil {
+		_ = db.index.Close()
+	}
+	if db.activeFile != nil {
+		_ = db.activeFile.Close()
+	}
+	for _, file := range db.olderFiles {
+		_ = file.Close()
+	}
+	if db.filelock != nil {
+		_ = db.filelock.Unlock()
+	}
 }
 
 // close bitcask db
 // free file lock and close all files
 func (db *DB) Close() error {
 	defer func() {
+		if db.filelock == nil {
+			return
+		}
 		if err := db.filelock.Unlock(); err != nil {
 			logrus.Fatalf("err %v, failed to unlock the dir %v", err, db.options.DirPath)
 		}
 	}()
 
-	if db.activeFile == nil {
-		return nil
-	}
+	// wait running merge finish
+	db.stopAutoMerge()
 
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	// persist keydir before closing it
+	if err := db.saveIndexCheckpoint(); err != nil {
+		return err
+	}
+
+	// read transactions held by snapshots of BPLUSTREE block closing it
+	for snap := range db.snapshots {
+		_ = snap.index.Close()
+	}
+
+	if err := db.index.Close(); err != nil {
+		return err
+	}
+
+	// wait for fsync of group commit
+	db.groupCommit.fileRefs.Lock()
+	defer db.groupCommit.fileRefs.Unlock()
+
+	for _, file := range db.retiredFiles {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	db.retiredFiles = nil
+
+	if db.activeFile == nil {
+		return nil
+	}
+
 	// clase active file
 	if err := db.activeFile.Close(); err != nil {
 		return err
@@ -179,6 +338,10 @@ func (db *DB) Sync() error {
 }
 
 func (db *DB) Backup(dir string) error {
+	if db.options.InMemory {
+		return ErrInMemory
+	}
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -188,23 +351,51 @@ func (db *DB) Backup(dir string) error {
 
 // Append <key, value> to active file
 func (db *DB) Put(key []byte, value []byte) error {
+	return db.put(key, value, 0)
+}
+
+// Append <key, value> to active file, key is treated as missing after ttl
+func (db *DB) PutWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	return db.put(key, value, time.Now().Add(ttl).UnixNano())
+}
+
+func (db *DB) put(key []byte, value []byte, expire int64) error {
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
-	//
-	logRecord := data.LogRecord{
-		Key:   logRecordKeyWithSeq(key, nonTxnSeqno),
-		Value: value,
-		Type:  data.LogRecordNormal,
+
+	// index must be updated under the same lock as append,
+	// so that order of index updates is the same as order of log records
+	return db.update(db.options.SyncWrite, func() error {
+		return db.appendPut(key, value, expire)
+	})
+}
+
+// append a put record and update index
+// caller must hold db.mu
+func (db *DB) appendPut(key []byte, value []byte, expire int64) error {
+	logRecord := &data.LogRecord{
+		Key:    logRecordKeyWithSeq(key, nonTxnSeqno),
+		Value:  value,
+		Type:   data.LogRecordNormal,
+		Expire: expire,
 	}
+
 	// append log record to active file, return logRecordPos(fd, offset) of record
-	pos, err := db.appendLogRecordWithLock(&logRecord)
+	pos, err := db.appendLogRecord(logRecord)
 	if err != nil {
 		return err
 	}
 
 	logrus.Debugf("[bitcask] %v put <%s, %s>, position <fid:%v, off:%v>\n", db.options.DirPath, key, value, pos.FileId, pos.Offset)
-	if oldpos := db.index.Put(key, pos); oldpos != nil {
+	oldpos, err := db.index.Put(key, pos)
+	if err != nil {
+		return err
+	}
+	if oldpos != nil {
 		db.reclaimSize += int64(oldpos.Size)
 	}
 
@@ -218,8 +409,16 @@ func (db *DB) Delete(key []byte) error {
 		return ErrKeyIsEmpty
 	}
 
-	if pos := db.index.Get(key); pos == nil {
-		return nil
+	return db.update(db.options.SyncWrite, func() error {
+		return db.appendDelete(key)
+	})
+}
+
+// append a delete record and remove key from index, nothing to do if key doesn't exist
+// caller must hold db.mu
+func (db *DB) appendDelete(key []byte) error {
+	if pos, err := db.index.Get(key); err != nil || pos == nil {
+		return err
 	}
 
 	logRecord := &data.LogRecord{
@@ -227,13 +426,16 @@ func (db *DB) Delete(key []byte) error {
 		Type: data.LogRecordDelete,
 	}
 
-	pos, err := db.appendLogRecordWithLock(logRecord)
+	pos, err := db.appendLogRecord(logRecord)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	db.reclaimSize += int64(pos.Size)
-	deletePos := db.index.Delete(key)
+	deletePos, err := db.index.Delete(key)
+	if err != nil {
+		return err
+	}
 	if deletePos == nil {
 		return ErrIndexUpdateFail
 	}
@@ -254,8 +456,11 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 	}
 
 	// get <fd, offset> from memory index(keydir)
-	pos := db.index.Get(key)
-	if pos == nil {
+	pos, err := db.index.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil || pos.Expired() {
 		return nil, ErrKeyNotFound
 	}
 	logrus.Debugf("[bitcask] %v get <%s>, position <fid:%v, off:%v>\n", db.options.DirPath, key, pos.FileId, pos.Offset)
@@ -265,12 +470,14 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 
 func (db *DB) ListKeys() [][]byte {
 	iter := db.index.Iterator(false)
-	keys := make([][]byte, db.index.Size())
+	defer iter.Close()
+	keys := make([][]byte, 0, db.keyNum())
 
-	idx := 0
 	for iter.Rewind(); iter.Valid(); iter.Next() {
-		keys[idx] = iter.Key()
-		idx += 1
+		if iter.Value().Expired() {
+			continue
+		}
+		keys = append(keys, iter.Key())
 	}
 
 	return keys
@@ -281,7 +488,11 @@ func (db *DB) Fold(fn func(key, val []byte) bool) error {
 	defer db.mu.RUnlock()
 
 	iter := db.index.Iterator(false)
+	defer iter.Close()
 	for iter.Rewind(); iter.Valid(); iter.Next() {
+		if iter.Value().Expired() {
+			continue
+		}
 		value, err := db.getValueByPostion(iter.Value())
 		if err != nil {
 			return err
@@ -294,13 +505,6 @@ func (db *DB) Fold(fn func(key, val []byte) bool) error {
 	return nil
 }
 
-func (db *DB) appendLogRecordWithLock(logRecord *data.LogRecord) (*data.LogRecordPos, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	return db.appendLogRecord(logRecord)
-}
-
 // helper function, appen logrecord to end of active file
 func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, error) {
 
@@ -311,8 +515,10 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 		}
 	}
 
+	logRecord = db.compressLogRecord(logRecord)
+
 	// encode log record and append it to active file
-	encRecord, size := data.EncodeLogRecord(logRecord)
+	encRecord, size := db.activeFile.EncodeLogRecord(logRecord)
 	/// if active chunk size is full, create a new active file
 	if db.activeFile.WriteOff+size > db.options.Maxsize {
 		// persist data fuke to Disk
@@ -324,6 +530,9 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 		if err := db.setActiveDataFile(); err != nil {
 			return nil, err
 		}
+
+		// new file may be encrypted by another key
+		encRecord, size = db.activeFile.EncodeLogRecord(logRecord)
 	}
 
 	start := db.activeFile.WriteOff
@@ -333,7 +542,8 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 
 	db.bytesWrite += uint64(size)
 
-	if (db.options.SyncThreshHold != 0 && db.bytesWrite >= db.options.SyncThreshHold) || db.options.SyncWrite {
+	// synchronous writes are persisted by group commit after db.mu is released
+	if db.options.SyncThreshHold != 0 && db.bytesWrite >= db.options.SyncThreshHold {
 		if err := db.activeFile.Sync(); err != nil {
 			return nil, err
 		}
@@ -344,65 +554,123 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 		FileId: db.activeFile.FileId,
 		Offset: start,
 		Size:   uint32(size),
+		Expire: logRecord.Expire,
 	}
 
 	return pos, nil
 }
 
+// return a copy of log record with compressed value if compression saves space,
+// record passed in is not modified
+func (db *DB) compressLogRecord(logRecord *data.LogRecord) *data.LogRecord {
+	if !db.options.Compression || logRecord.Type != data.LogRecordNormal ||
+		len(logRecord.Value) < db.options.CompressThreshold {
+		return logRecord
+	}
+
+	compressed := data.CompressValue(logRecord.Value)
+	if len(compressed) >= len(logRecord.Value) {
+		return logRecord
+	}
+
+	record := *logRecord
+	record.Value = compressed
+	record.Compressed = true
+	return &record
+}
+
 // create a new active datafile
 // caller must be hold lock
 func (db *DB) setActiveDataFile() error {
 	var activeFileId uint32 = 0
 
 	if db.activeFile != nil {
-		db.olderFiles[db.activeFile.FileId] = db.activeFile
 		activeFileId = db.activeFile.FileId + 1
 	}
 
-	dataFile, err := data.OpenDataFile(db.options.DirPath, activeFileId, fio.StandFileIO)
+	return db.setActiveDataFileWithId(activeFileId)
+}
+
+// create a new active datafile with file id, current active file becomes older file
+// caller must be hold lock
+func (db *DB) setActiveDataFileWithId(activeFileId uint32) error {
+	if db.activeFile != nil {
+		// drop space pre-grown by writable mmap, file is never appended again
+		if db.fileIOType() == fio.WritableMemoryMapIO {
+			if err := db.activeFile.IoManager.Truncate(db.activeFile.WriteOff); err != nil {
+				return err
+			}
+			if err := db.activeFile.Sync(); err != nil {
+				return err
+			}
+		}
+		db.olderFiles[db.activeFile.FileId] = db.activeFile
+	}
+
+	dataFile, err := db.openDataFile(db.options.DirPath, activeFileId, db.fileIOType())
 	if err != nil {
 		return err
 	}
+	// new file is encrypted by current key
+	if err := dataFile.InitFileHeader(db.keyring); err != nil {
+		_ = dataFile.Close()
+		return err
+	}
 	logrus.Debugf("[bitcask] set active data file %v\n", activeFileId)
 	db.activeFile = dataFile
 	return nil
 }
 
 func checkOptions(options Options) error {
-	if options.DirPath == "" {
+	if options.DirPath == "" && !options.InMemory {
 		return errors.New("database dir path is empty")
 	}
+	if options.InMemory && options.ReadOnly {
+		return errors.New("in-memory database can't be read-only")
+	}
 	if options.Maxsize <= 0 {
 		return errors.New("database data file size must be greater than 0")
 	}
+	// MemoryIO is only used by in-memory db, io type is ignored by it
+	if !options.InMemory && options.IOType != fio.StandFileIO &&
+		options.IOType != fio.WritableMemoryMapIO && options.IOType != fio.BufferedIO {
+		return errors.New("io type of data files must be StandFileIO, WritableMemoryMapIO or BufferedIO")
+	}
 	if options.MergeRatio <= 0 || options.MergeRatio >= 1 {
 		return errors.New("unvalid merge ration which should 0 < mergeratio < 1")
 	}
-
-	return nil
-}
-
-// helper functions, add keyDir item to memory Index
-func (db *DB) updateIndex(key []byte, typ data.LogRecordType, pos *data.LogRecordPos) {
-	var oldPos *data.LogRecordPos
-	if typ == data.LogRecordDelete {
-		oldPos = db.index.Delete(key)
-		db.reclaimSize += int64(pos.Size)
-	} else {
-		oldPos = db.index.Put(key, pos)
+	if len(options.EncryptKeys) > 0 {
+		if _, ok := options.EncryptKeys[options.EncryptKeyId]; !ok {
+			return errors.New("encrypt key of EncryptKeyId is not found")
+		}
+		for _, key := range options.EncryptKeys {
+			if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+				return errors.New("encrypt key must be 16, 24 or 32 bytes")
+			}
+		}
 	}
-
-	if oldPos != nil {
-		db.reclaimSize += int64(oldPos.Size)
+	if options.ValueCacheSize < 0 {
+		return errors.New("value cache size must be greater equal than 0")
+	}
+	if options.CompressThreshold < 0 {
+		return errors.New("compress threshold must be greater equal than 0")
+	}
+	if options.AutoMergeInterval < 0 {
+		return errors.New("auto merge interval must be greater equal than 0")
+	}
+	if options.AutoMergeStartHour < 0 || options.AutoMergeStartHour > 23 ||
+		options.AutoMergeEndHour < 0 || options.AutoMergeEndHour > 23 {
+		return errors.New("auto merge hour should be 0 ~ 23")
 	}
 
+	return nil
 }
 
-// load datafile from disk
-func (db *DB) loadDataFile() error {
-	dirEntry, err := os.ReadDir(db.options.DirPath)
+// return sorted ids of data files in dirPath
+func getDataFileIds(dirPath string) ([]int, error) {
+	dirEntry, err := os.ReadDir(dirPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var fileIds []int
@@ -414,7 +682,7 @@ func (db *DB) loadDataFile() error {
 
 			// data file may be corrupted
 			if err != nil {
-				return ErrDataDirectoryCorrupted
+				return nil, ErrDataDirectoryCorrupted
 			}
 
 			fileIds = append(fileIds, fileId)
@@ -422,14 +690,62 @@ func (db *DB) loadDataFile() error {
 	}
 
 	sort.Ints(fileIds)
+	return fileIds, nil
+}
+
+// keys of encryption in options, nil if encryption is disabled
+func newKeyring(options Options) *data.Keyring {
+	if len(options.EncryptKeys) == 0 {
+		return nil
+	}
+
+	return &data.Keyring{
+		CurrentId: options.EncryptKeyId,
+		Keys:      options.EncryptKeys,
+	}
+}
+
+// helper functions, add keyDir item to memory Index
+// expired record is treated as a delete record
+func (db *DB) updateIndex(key []byte, typ data.LogRecordType, pos *data.LogRecordPos) error {
+	var oldPos *data.LogRecordPos
+	var err error
+	if typ == data.LogRecordDelete || pos.Expired() {
+		oldPos, err = db.index.Delete(key)
+		db.reclaimSize += int64(pos.Size)
+	} else {
+		oldPos, err = db.index.Put(key, pos)
+	}
+	if err != nil {
+		return err
+	}
+
+	if oldPos != nil {
+		db.reclaimSize += int64(oldPos.Size)
+	}
+
+	return nil
+}
+
+// load datafile from disk
+func (db *DB) loadDataFile() error {
+	fileIds, err := getDataFileIds(db.options.DirPath)
+	if err != nil {
+		return err
+	}
 	db.fileIds = fileIds
 
 	for i, fid := range fileIds {
-		iotyp := fio.StandFileIO
-		if db.options.MMapAtStartup {
-			iotyp = fio.MemoryMapIO
+		iotyp := db.startupIOType()
+		dataFile, err := db.openDataFile(db.options.DirPath, uint32(fid), iotyp)
+		if err == data.ErrInvalidFileHeader && i == len(fileIds)-1 {
+			// file is being created by writer and has no record
+			if db.options.ReadOnly {
+				db.fileIds = fileIds[:i]
+				break
+			}
+			dataFile, err = db.reopenTornHeaderFile(uint32(fid), iotyp)
 		}
-		dataFile, err := data.OpenDataFile(db.options.DirPath, uint32(fid), iotyp)
 		if err != nil {
 			return err
 		}
@@ -460,17 +776,30 @@ func (db *DB) loadIndexFromDateFile() error {
 		}
 		nonMergeFid = fid
 	}
+	db.nonMergeFid = nonMergeFid
 
-	// cache tranction operation
-	txnRecords := make(map[uint64][]*data.TransactionRecord)
+	// persistent keydir only needs to replay records after checkpoint
+	var startFid uint32 = 0
+	var startOffset int64 = 0
 	curSeqNo := nonTxnSeqno
+	if db.checkpoint != nil {
+		startFid = db.checkpoint.fileId
+		startOffset = db.checkpoint.offset
+		curSeqNo = db.checkpoint.txnSeqNo
+	}
+
+	// cache tranction operation, read-only db keeps them until refresh
+	txnRecords := db.pendingTxns
+	if txnRecords == nil {
+		txnRecords = make(map[uint64][]*data.TransactionRecord)
+	}
 
 	// lood must be order by file Id due to log structured
 	for i, fid := range db.fileIds {
 		var fileid = uint32(fid)
 
 		// skip file has been merged
-		if fileid < nonMergeFid {
+		if fileid < nonMergeFid || fileid < startFid {
 			continue
 		}
 
@@ -480,27 +809,41 @@ func (db *DB) loadIndexFromDateFile() error {
 		} else {
 			dataFile = db.olderFiles[fileid]
 		}
-		var offset int64 = 0
+		var offset = dataFile.HeaderSize
+		if fileid == startFid && startOffset > offset {
+			offset = startOffset
+		}
+		var tailErr error
 		for {
 			logRecord, size, err := dataFile.ReadLogRecord(offset)
 			if err == io.EOF {
 				break
 			}
+			// last record of last file may be torn by crash
+			if err != nil && i == len(db.fileIds)-1 && db.isCorruptTail(dataFile, offset, size, err) {
+				tailErr = err
+				break
+			}
 			if err != nil { // err not nil and not eof
-				return err
+				return fmt.Errorf("%w, file %v offset %v: %v", ErrDataFileCorrupted, fileid, offset, err)
 			}
 
 			// insert keydir entry to index
-			pos := &data.LogRecordPos{FileId: fileid, Offset: offset, Size: uint32(size)}
+			pos := &data.LogRecordPos{FileId: fileid, Offset: offset, Size: uint32(size), Expire: logRecord.Expire}
 			realKey, seqNo := parseLogRecordWithSeq(logRecord.Key)
 
 			// Not write batch
-			if seqNo == nonTxnSeqno {
-				db.updateIndex(realKey, logRecord.Type, pos)
+			if seqNo == nonTxnSeqno && logRecord.Type == data.LogRecordRangeDelete {
+				db.reclaimSize += int64(size)
+				err = db.deleteIndexRange(realKey, logRecord.Value)
+			} else if seqNo == nonTxnSeqno {
+				err = db.updateIndex(realKey, logRecord.Type, pos)
 			} else {
 				if logRecord.Type == data.LogRecordTxnFin {
 					for _, batchRecord := range txnRecords[seqNo] {
-						db.updateIndex(batchRecord.Record.Key, batchRecord.Record.Type, batchRecord.Pos)
+						if err = db.updateIndex(batchRecord.Record.Key, batchRecord.Record.Type, batchRecord.Pos); err != nil {
+							break
+						}
 					}
 					delete(txnRecords, seqNo)
 				} else {
@@ -513,6 +856,9 @@ func (db *DB) loadIndexFromDateFile() error {
 					txnRecords[seqNo] = append(txnRecords[seqNo], txnRecord)
 				}
 			}
+			if err != nil {
+				return err
+			}
 
 			if seqNo > curSeqNo {
 				curSeqNo = seqNo
@@ -522,22 +868,155 @@ func (db *DB) loadIndexFromDateFile() error {
 		}
 
 		if i == len(db.fileIds)-1 {
+			fileSize, err := dataFile.Size()
+			if err != nil {
+				return err
+			}
+			// space pre-grown by writable mmap is left at the end by crash, it isn't corruption
+			if offset < fileSize {
+				zeroTail, err := db.dropZeroTail(dataFile, offset, fileSize)
+				if err != nil {
+					return err
+				}
+				if zeroTail {
+					tailErr = nil
+				} else if tailErr == nil {
+					// file io appends after bytes left at the end
+					tailErr = io.ErrUnexpectedEOF
+				}
+			}
+			if tailErr != nil {
+				if err := db.truncateCorruptTail(dataFile, offset, tailErr); err != nil {
+					return err
+				}
+			}
 			db.activeFile.WriteOff = offset
 		}
 	}
 
 	db.txnSeqNo = curSeqNo
+	if db.options.ReadOnly {
+		db.pendingTxns = txnRecords
+	}
 
 	return nil
 }
 
+// incomplete record or crc checking failed record at the end of file,
+// a corrupted size also makes record run past the end, so it's only a tail
+// if no complete record can be found after it
+func (db *DB) isCorruptTail(dataFile *data.DataFile, offset, size int64, err error) bool {
+	if err != io.ErrUnexpectedEOF && err != data.ErrInvalidCRC {
+		return false
+	}
+
+	if err == data.ErrInvalidCRC {
+		fileSize, sizeErr := dataFile.Size()
+		if sizeErr != nil || offset+size < fileSize {
+			return false
+		}
+	}
+
+	found, scanErr := dataFile.HasRecordAfter(offset)
+	return scanErr == nil && !found
+}
+
+// drop all bytes of data file after offset
+func (db *DB) truncateCorruptTail(dataFile *data.DataFile, offset int64, reason error) error {
+	// tail may be being written by writer, it's read again at refresh
+	if db.options.ReadOnly {
+		return nil
+	}
+	if !db.options.TruncateCorruptTail {
+		return fmt.Errorf("%w, file %v offset %v: %v", ErrDataFileCorrupted, dataFile.FileId, offset, reason)
+	}
+
+	fileSize, err := dataFile.Size()
+	if err != nil {
+		return err
+	}
+
+	logrus.Warnf("[Bitcask] truncate corrupted tail of file %v at offset %v, %v bytes dropped: %v",
+		dataFile.FileId, offset, fileSize-offset, reason)
+
+	return os.Truncate(data.GetDataFileName(db.options.DirPath, dataFile.FileId), offset)
+}
+
+// drop bytes after offset if they are all zero, return false if any byte isn't zero
+func (db *DB) dropZeroTail(dataFile *data.DataFile, offset, fileSize int64) (bool, error) {
+	buf, err := dataFile.ReadNBytes(fileSize-offset, offset)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range buf {
+		if b != 0 {
+			return false, nil
+		}
+	}
+
+	// tail may be being written by writer, it's read again at refresh
+	if db.options.ReadOnly {
+		return true, nil
+	}
+
+	logrus.Infof("[Bitcask] drop %v zero bytes at the end of file %v", fileSize-offset, dataFile.FileId)
+	return true, os.Truncate(data.GetDataFileName(db.options.DirPath, dataFile.FileId), offset)
+}
+
+// header of last file may be partially written before crash,
+// the file has no record so it's truncated to empty and header is written again
+func (db *DB) reopenTornHeaderFile(fid uint32, iotype fio.FileIOType) (*data.DataFile, error) {
+	fileName := data.GetDataFileName(db.options.DirPath, fid)
+	fileInfo, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.Size() >= data.FileHeaderSize {
+		return nil, data.ErrInvalidFileHeader
+	}
+	if !db.options.TruncateCorruptTail {
+		return nil, fmt.Errorf("%w, file %v offset 0: %v", ErrDataFileCorrupted, fid, data.ErrInvalidFileHeader)
+	}
+
+	logrus.Warnf("[Bitcask] truncate torn header of file %v, %v bytes dropped", fid, fileInfo.Size())
+	if err := os.Truncate(fileName, 0); err != nil {
+		return nil, err
+	}
+
+	return db.openDataFile(db.options.DirPath, fid, iotype)
+}
+
+// caller must hold db.mu
 func (db *DB) getValueByPostion(pos *data.LogRecordPos) ([]byte, error) {
-	logrus.Infof("get value from file %v, offset %v\n", pos.FileId, pos.Offset)
+	if db.syncErr != nil {
+		return nil, db.syncErr
+	}
+
+	if db.valueCache != nil && !pos.Expired() {
+		if value, ok := db.valueCache.get(pos); ok {
+			return value, nil
+		}
+	}
+
 	datafile := db.activeFile
 	if pos.FileId != datafile.FileId {
 		datafile = db.olderFiles[pos.FileId]
 	}
 
+	value, err := readValueFromFile(datafile, pos)
+	if err == nil && db.valueCache != nil {
+		db.valueCache.put(pos, value)
+	}
+	return value, err
+}
+
+// read value of normal log record at pos from datafile
+func readValueFromFile(datafile *data.DataFile, pos *data.LogRecordPos) ([]byte, error) {
+	logrus.Infof("get value from file %v, offset %v\n", pos.FileId, pos.Offset)
+	if pos.Expired() {
+		return nil, ErrKeyNotFound
+	}
+
 	if datafile == nil {
 		return nil, ErrDataFileNotFound
 	}
@@ -554,19 +1033,94 @@ func (db *DB) getValueByPostion(pos *data.LogRecordPos) ([]byte, error) {
 	return logrecord.Value, nil
 }
 
+// io type of data files after startup
+func (db *DB) fileIOType() fio.FileIOType {
+	if db.options.InMemory {
+		return fio.MemoryIO
+	}
+	if db.options.ReadOnly {
+		return fio.ReadOnlyFileIO
+	}
+	return db.options.IOType
+}
+
+// io type of data files used to load them at startup,
+// read-only db doesn't mmap files, so records appended by writer can be read
+func (db *DB) startupIOType() fio.FileIOType {
+	if db.options.ReadOnly {
+		return fio.ReadOnlyFileIO
+	}
+	if db.options.MMapAtStartup {
+		return fio.MemoryMapIO
+	}
+	return fio.StandFileIO
+}
+
+// switch io type of files used at startup to io type of options
 func (db *DB) resetIoType() error {
 	if db.activeFile == nil {
 		return nil
 	}
-	if err := db.activeFile.SetIoMananger(db.options.DirPath, fio.StandFileIO); err != nil {
+	if err := db.setIoManager(db.activeFile, db.fileIOType()); err != nil {
 		return err
 	}
 
 	for _, datafile := range db.olderFiles {
-		if err := datafile.SetIoMananger(db.options.DirPath, fio.StandFileIO); err != nil {
+		if err := db.setIoManager(datafile, db.fileIOType()); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// open data file of fid in dirPath, its io manager is wrapped by options
+func (db *DB) openDataFile(dirPath string, fid uint32, iotype fio.FileIOType) (*data.DataFile, error) {
+	dataFile, err := data.OpenDataFile(dirPath, fid, iotype, db.keyring)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.wrapFileIO(dataFile, data.GetDataFileName(dirPath, fid)); err != nil {
+		return nil, err
+	}
+
+	return dataFile, nil
+}
+
+// open hint file in dirPath, its io manager is wrapped by options
+func (db *DB) openHintFile(dirPath string, iotype fio.FileIOType) (*data.DataFile, error) {
+	hintFile, err := data.OpenHintFile(dirPath, iotype, db.keyring)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.wrapFileIO(hintFile, filepath.Join(dirPath, data.HintFileName)); err != nil {
+		return nil, err
+	}
+
+	return hintFile, nil
+}
+
+// reopen data file with io type, its io manager is wrapped by options
+func (db *DB) setIoManager(dataFile *data.DataFile, iotype fio.FileIOType) error {
+	if err := dataFile.SetIoMananger(db.options.DirPath, iotype); err != nil {
+		return err
+	}
+
+	return db.wrapFileIO(dataFile, data.GetDataFileName(db.options.DirPath, dataFile.FileId))
+}
+
+// replace io manager of file by the one wrapped by options.IOWrapper,
+// manager is closed by wrapper if it fails
+func (db *DB) wrapFileIO(file *data.DataFile, filename string) error {
+	if db.options.IOWrapper == nil {
+		return nil
+	}
+
+	manager, err := db.options.IOWrapper(filename, file.IoManager)
+	if err != nil {
+		return err
+	}
+	file.IoManager = manager
+
+	return nil
+}
@@ -1,9 +1,16 @@
 package bitcaskgo
 
 import (
+	"bitcask-go/data"
+	"bitcask-go/fio"
+	"bitcask-go/index"
 	"bitcask-go/utils"
+	"bytes"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -416,3 +423,726 @@ func TestDB_Stat(t *testing.T) {
 	stat := db.Stat()
 	t.Log(stat)
 }
+
+func TestDB_ARTIndex(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-art")
+	opts.DirPath = dir
+	opts.Index = index.ARTREE
+
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 500; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+
+	keys := db.ListKeys()
+	assert.Equal(t, 500, len(keys))
+	for i, key := range keys {
+		assert.Equal(t, utils.GetTestKey(i+500), key)
+	}
+
+	val, err := db.Get(utils.GetTestKey(600))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(600, 1), val)
+}
+
+func TestDB_BPlusTreeIndex(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree")
+	opts.DirPath = dir
+	opts.Index = index.BPLUSTREE
+
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 100; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	// 1. restart from checkpoint
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, db.checkpoint)
+	assert.Equal(t, 900, len(db.ListKeys()))
+
+	// checkpoint is removed once db is opened
+	cp, err := db.index.(index.PersistentIndexer).LoadCheckpoint()
+	assert.Nil(t, err)
+	assert.Nil(t, cp)
+
+	val, err := db.Get(utils.GetTestKey(500))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(500, 1), val)
+
+	// 2. crash without saving checkpoint, keydir is rebuilt from data files
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	for i := 100; i < 200; i++ {
+		assert.Nil(t, wb.Delete(utils.GetTestKey(i)))
+	}
+	assert.Nil(t, wb.Commit())
+	for i := 1000; i < 1100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 2))
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, db.index.Close())
+	assert.Nil(t, db.activeFile.Close())
+	assert.Nil(t, db.filelock.Unlock())
+
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.checkpoint)
+	assert.Equal(t, 900, len(db.ListKeys()))
+
+	_, err = db.Get(utils.GetTestKey(150))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db.Get(utils.GetTestKey(1050))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(1050, 2), val)
+
+	// 3. checkpoint is saved again by Close
+	assert.Nil(t, db.Close())
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, db.checkpoint)
+	assert.Equal(t, 900, len(db.ListKeys()))
+
+	assert.Nil(t, db.Close())
+}
+
+func TestDB_BPlusTreeIndexStaleCheckpoint(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-checkpoint")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+	opts.Index = index.BPLUSTREE
+	opts.IOType = fio.BufferedIO
+
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put([]byte("a"), []byte("1")))
+	assert.Nil(t, db.Sync())
+	fileName := data.GetDataFileName(dir, db.activeFile.FileId)
+	stat, err := os.Stat(fileName)
+	assert.Nil(t, err)
+	syncedSize := stat.Size()
+
+	// buffered record is flushed before checkpoint is saved
+	assert.Nil(t, db.Put([]byte("b"), []byte("2")))
+	assert.Nil(t, db.Close())
+	stat, err = os.Stat(fileName)
+	assert.Nil(t, err)
+	assert.Greater(t, stat.Size(), syncedSize)
+
+	// unsynced tail is lost, checkpoint is beyond the end of file
+	assert.Nil(t, os.Truncate(fileName, syncedSize))
+
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.checkpoint)
+	assert.Nil(t, db.Put([]byte("c"), []byte("3")))
+
+	val, err := db.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), val)
+	_, err = db.Get([]byte("b"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db.Get([]byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("3"), val)
+	assert.Nil(t, db.Close())
+}
+
+func TestDB_PutWithTTL(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-ttl")
+	opts.DirPath = dir
+
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.PutWithTTL(utils.GetTestKey(1), utils.GetTestValue(1, 1), 0)
+	assert.Equal(t, ErrInvalidTTL, err)
+
+	err = db.PutWithTTL(utils.GetTestKey(1), utils.GetTestValue(1, 1), 100*time.Millisecond)
+	assert.Nil(t, err)
+	err = db.PutWithTTL(utils.GetTestKey(2), utils.GetTestValue(2, 1), time.Hour)
+	assert.Nil(t, err)
+	err = db.Put(utils.GetTestKey(3), utils.GetTestValue(3, 1))
+	assert.Nil(t, err)
+
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(1, 1), val)
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Equal(t, 2, len(db.ListKeys()))
+
+	iter := db.NewIterator(DefaultIterOptions)
+	assert.Equal(t, utils.GetTestKey(2), iter.Key())
+	iter.Close()
+
+	// put without ttl overwrite expire
+	err = db.PutWithTTL(utils.GetTestKey(3), utils.GetTestValue(3, 2), 100*time.Millisecond)
+	assert.Nil(t, err)
+	err = db.Put(utils.GetTestKey(3), utils.GetTestValue(3, 3))
+	assert.Nil(t, err)
+
+	// restart, expire time is kept in data file
+	err = db.Close()
+	assert.Nil(t, err)
+	time.Sleep(200 * time.Millisecond)
+
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	_, err = db2.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db2.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(2, 1), val)
+	val, err = db2.Get(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(3, 3), val)
+
+	// expired records are dropped by merge, expire time is kept in hint file
+	err = db2.PutWithTTL(utils.GetTestKey(4), utils.GetTestValue(4, 1), 100*time.Millisecond)
+	assert.Nil(t, err)
+	time.Sleep(200 * time.Millisecond)
+	err = db2.Merge()
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+
+	db3, err := OpenDB(opts)
+	assert.Nil(t, err)
+	size, err := db3.index.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, size)
+	pos, err := db3.index.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.NotNil(t, pos)
+	assert.NotEqual(t, int64(0), pos.Expire)
+	assert.Nil(t, db3.Close())
+}
+
+// append bytes to the end of data file to simulate torn write
+func appendToDataFile(t *testing.T, dirPath string, fid uint32, buf []byte) {
+	file, err := os.OpenFile(data.GetDataFileName(dirPath, fid), os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.Write(buf)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+}
+
+func TestDB_OpenCorruptTail(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-corrupt-tail")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+	activeFid := db.activeFile.FileId
+	fileSize := db.activeFile.WriteOff
+	assert.Nil(t, db.Close())
+
+	// half of a record is written before crash
+	encRecord, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq(utils.GetTestKey(100), nonTxnSeqno),
+		Value: utils.GetTestValue(100, 1),
+	})
+	appendToDataFile(t, dir, activeFid, encRecord[:len(encRecord)/2])
+
+	// refuse to open
+	strictOpts := opts
+	strictOpts.TruncateCorruptTail = false
+	_, err = OpenDB(strictOpts)
+	assert.True(t, errors.Is(err, ErrDataFileCorrupted))
+
+	db, err = OpenDB(opts)
+	defer func() {
+		destroyDB(db)
+	}()
+	assert.Nil(t, err)
+	assert.Equal(t, fileSize, db.activeFile.WriteOff)
+	assert.Equal(t, 100, len(db.ListKeys()))
+
+	// bad crc of last record
+	assert.Nil(t, db.Close())
+	encRecord[len(encRecord)-1] ^= 0xff
+	appendToDataFile(t, dir, activeFid, encRecord)
+
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, fileSize, db.activeFile.WriteOff)
+
+	// new records are appended after truncated position
+	err = db.Put(utils.GetTestKey(100), utils.GetTestValue(100, 2))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 101, len(db.ListKeys()))
+	val, err := db.Get(utils.GetTestKey(100))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(100, 2), val)
+}
+
+func TestDB_OpenCorruptRecordSize(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-corrupt-size")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, db.Put([]byte(key), []byte(key)))
+	}
+	assert.Nil(t, db.Close())
+
+	// key size of first record is corrupted, record runs past the end of file
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	copy(buf[data.FileHeaderSize+5:], []byte{0xff, 0xff, 0xff, 0x7f})
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	// records after it aren't dropped as a torn tail
+	_, err = OpenDB(opts)
+	assert.True(t, errors.Is(err, ErrDataFileCorrupted))
+	assert.Contains(t, err.Error(), "file 0")
+
+	stat, err := os.Stat(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(buf)), stat.Size())
+
+	_ = os.RemoveAll(dir)
+}
+
+func TestDB_OpenCorruptOlderFile(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-corrupt-older")
+	opts.DirPath = dir
+	opts.Maxsize = 4 * 1024
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 500; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+	assert.Greater(t, len(db.olderFiles), 0)
+	assert.Nil(t, db.Close())
+
+	// torn tail of an older file isn't truncated
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	encRecord, _ := data.EncodeLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq(utils.GetTestKey(500), nonTxnSeqno),
+		Value: utils.GetTestValue(500, 1),
+	})
+	appendToDataFile(t, dir, 0, encRecord[:len(encRecord)/2])
+
+	_, err = OpenDB(opts)
+	assert.True(t, errors.Is(err, ErrDataFileCorrupted))
+	assert.Contains(t, err.Error(), "file 0")
+
+	// flip a byte in the middle of first file
+	buf[len(buf)/2] ^= 0xff
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	_, err = OpenDB(opts)
+	assert.True(t, errors.Is(err, ErrDataFileCorrupted))
+	assert.Contains(t, err.Error(), "file 0")
+
+	_ = os.RemoveAll(dir)
+}
+
+func TestDB_Compression(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-compression")
+	opts.DirPath = dir
+	opts.Maxsize = 64 * 1024
+	opts.Compression = true
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	bigValue := func(i int) []byte {
+		return bytes.Repeat([]byte(fmt.Sprintf(`{"id":%d,"name":"bitcask-go"},`, i)), 50)
+	}
+
+	var rawSize int
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), bigValue(i))
+		assert.Nil(t, err)
+		rawSize += len(bigValue(i))
+	}
+	// small value stays uncompressed
+	err = db.Put([]byte("small"), []byte("small-value"))
+	assert.Nil(t, err)
+
+	diskSize, err := utils.DirSize(dir)
+	assert.Nil(t, err)
+	assert.Less(t, diskSize, int64(rawSize/5))
+
+	check := func(db *DB) {
+		for i := 0; i < 100; i++ {
+			val, err := db.Get(utils.GetTestKey(i))
+			assert.Nil(t, err)
+			assert.Equal(t, bigValue(i), val)
+		}
+		val, err := db.Get([]byte("small"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("small-value"), val)
+
+		iter := db.NewIterator(DefaultIterOptions)
+		val, err = iter.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, bigValue(0), val)
+		iter.Close()
+
+		count := 0
+		err = db.Fold(func(key, val []byte) bool {
+			count++
+			return len(val) > 0
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 101, count)
+	}
+	check(db)
+
+	for i := 0; i < 50; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 50; i++ {
+		err := db.Put(utils.GetTestKey(i), bigValue(i))
+		assert.Nil(t, err)
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+	check(db)
+
+	// values are readable after loading hint file
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	defer func() {
+		_ = db2.Close()
+	}()
+	check(db2)
+}
+
+func TestDB_Encryption(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-encryption")
+	opts.DirPath = dir
+	opts.Maxsize = 16 * 1024
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+
+	// plaintext files written before encryption enabled
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), []byte(fmt.Sprintf("secret-%d", i)))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, db.Close())
+
+	key1 := bytes.Repeat([]byte{1}, 16)
+	key2 := bytes.Repeat([]byte{2}, 32)
+	opts.EncryptKeys = map[uint32][]byte{1: key1}
+	opts.EncryptKeyId = 1
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	// records are not appended to plaintext active file
+	assert.Equal(t, uint32(1), db.activeFile.KeyId)
+	assert.Nil(t, db.Put(utils.GetTestKey(100), []byte("secret-100")))
+	buf, err := os.ReadFile(data.GetDataFileName(dir, db.activeFile.FileId))
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(buf, []byte("secret-")))
+	for i := 101; i < 500; i++ {
+		err := db.Put(utils.GetTestKey(i), []byte(fmt.Sprintf("secret-%d", i)))
+		assert.Nil(t, err)
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	// nothing is stored in plaintext after merge
+	containsPlaintext := func() bool {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			buf, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if bytes.Contains(buf, []byte("secret-")) || bytes.Contains(buf, []byte("key-")) {
+				return true
+			}
+		}
+		return false
+	}
+	assert.False(t, containsPlaintext())
+
+	check := func(db *DB) {
+		for i := 0; i < 500; i++ {
+			val, err := db.Get(utils.GetTestKey(i))
+			assert.Nil(t, err)
+			assert.Equal(t, []byte(fmt.Sprintf("secret-%d", i)), val)
+		}
+	}
+	check(db)
+	assert.Nil(t, db.Close())
+
+	// rotate key, merge re-encrypts old files with current key
+	opts.EncryptKeys = map[uint32][]byte{1: key1, 2: key2}
+	opts.EncryptKeyId = 2
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), db.activeFile.KeyId)
+	for i := 0; i < 100; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		err = db.Put(utils.GetTestKey(i), []byte(fmt.Sprintf("secret-%d", i)))
+		assert.Nil(t, err)
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+	for _, file := range db.olderFiles {
+		assert.Equal(t, uint32(2), file.KeyId)
+	}
+	assert.Equal(t, uint32(2), db.activeFile.KeyId)
+	assert.False(t, containsPlaintext())
+	assert.Nil(t, db.Close())
+
+	// old key is not needed any more
+	opts.EncryptKeys = map[uint32][]byte{2: key2}
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	check(db)
+	assert.Nil(t, db.Close())
+
+	opts.EncryptKeys = nil
+	_, err = OpenDB(opts)
+	assert.Equal(t, data.ErrEncryptKeyNotFound, err)
+
+	_ = os.RemoveAll(dir)
+}
+
+func TestDB_OpenHeaderlessFiles(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-headerless")
+	opts.DirPath = dir
+	opts.Maxsize = 4 * 1024
+
+	// data files written by old version have no file header
+	for fid := uint32(0); fid < 2; fid++ {
+		var buf []byte
+		for i := int(fid) * 50; i < int(fid+1)*50; i++ {
+			encRecord, _ := data.EncodeLogRecord(&data.LogRecord{
+				Key:   logRecordKeyWithSeq(utils.GetTestKey(i), nonTxnSeqno),
+				Value: utils.GetTestValue(i, 1),
+			})
+			buf = append(buf, encRecord...)
+		}
+		assert.Nil(t, os.WriteFile(data.GetDataFileName(dir, fid), buf, 0644))
+	}
+
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), db.activeFile.HeaderSize)
+
+	for i := 0; i < 100; i++ {
+		val, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+
+	// new files are created with header
+	for i := 100; i < 300; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+	assert.Equal(t, data.FileHeaderVersion, db.activeFile.Version)
+	assert.Equal(t, int64(data.FileHeaderSize), db.activeFile.HeaderSize)
+
+	assert.Nil(t, db.Close())
+
+	// header of new active file is partially written
+	header, err := os.ReadFile(data.GetDataFileName(dir, db.activeFile.FileId))
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(data.GetDataFileName(dir, db.activeFile.FileId+1), header[:10], 0644))
+
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(data.FileHeaderSize), db2.activeFile.WriteOff)
+	for i := 0; i < 300; i++ {
+		val, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+	assert.Nil(t, db2.Close())
+}
+
+func TestDB_WritableMMap(t *testing.T) {
+	testDBWithIOType(t, fio.WritableMemoryMapIO)
+}
+
+func TestDB_BufferedIO(t *testing.T) {
+	testDBWithIOType(t, fio.BufferedIO)
+}
+
+func testDBWithIOType(t *testing.T, ioType fio.FileIOType) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-iotype")
+	opts.DirPath = dir
+	opts.Maxsize = 16 * 1024
+	opts.IOType = ioType
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+	for i := 0; i < 1000; i += 2 {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+
+	check := func(db *DB) {
+		for i := 0; i < 1000; i++ {
+			val, err := db.Get(utils.GetTestKey(i))
+			if i%2 == 0 {
+				assert.Equal(t, ErrKeyNotFound, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, utils.GetTestValue(i, 1), val)
+			}
+		}
+	}
+	check(db)
+	assert.Nil(t, db.Sync())
+
+	// rotated files have no pre-grown space
+	assert.Greater(t, len(db.olderFiles), 0)
+	for fid, file := range db.olderFiles {
+		stat, err := os.Stat(data.GetDataFileName(dir, fid))
+		assert.Nil(t, err)
+		assert.Equal(t, file.WriteOff, stat.Size())
+	}
+
+	assert.Nil(t, db.Merge())
+	check(db)
+
+	// copy of dir may have pre-grown zero bytes at the end of active file like a crash,
+	// they aren't treated as corruption
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-iotype-backup")
+	assert.Nil(t, db.Backup(backupDir))
+	backupOpts := opts
+	backupOpts.DirPath = backupDir
+	backupOpts.TruncateCorruptTail = false
+	backup, err := OpenDB(backupOpts)
+	assert.Nil(t, err)
+	check(backup)
+	destroyDB(backup)
+
+	assert.Nil(t, db.Close())
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	check(db2)
+	assert.Nil(t, db2.Close())
+}
+
+func TestDB_InMemory(t *testing.T) {
+	opts := DefaultOptions
+	opts.DirPath = filepath.Join(os.TempDir(), "bitcask-go-in-memory-not-exist")
+	opts.Maxsize = 64 * 1024
+	opts.Index = index.BPLUSTREE
+	opts.InMemory = true
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	// other db with the same dir doesn't conflict
+	other, err := OpenDB(opts)
+	assert.Nil(t, err)
+	_, err = other.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Nil(t, other.Close())
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.RandomValue(128)))
+	}
+	for i := 0; i < 1000; i += 2 {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	assert.Nil(t, db.Sync())
+	assert.Greater(t, db.Stat().DataFileNum, uint(1))
+
+	for i := 0; i < 1000; i++ {
+		_, err := db.Get(utils.GetTestKey(i))
+		if i%2 == 0 {
+			assert.Equal(t, ErrKeyNotFound, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+	assert.Equal(t, 500, len(db.ListKeys()))
+
+	assert.Equal(t, ErrInMemory, db.Merge())
+	assert.Equal(t, ErrInMemory, db.Backup(opts.DirPath+"-backup"))
+
+	_, err = os.Stat(opts.DirPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDB_InvalidIOType(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-invalid-iotype")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+
+	// MemoryIO is only used by in-memory db
+	for _, ioType := range []fio.FileIOType{fio.MemoryMapIO, fio.MemoryIO, 100} {
+		opts.IOType = ioType
+		db, err := OpenDB(opts)
+		assert.NotNil(t, err)
+		assert.Nil(t, db)
+	}
+
+	opts.IOType = fio.MemoryIO
+	opts.InMemory = true
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+}
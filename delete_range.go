@@ -0,0 +1,96 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"bytes"
+)
+
+// ---- range delete ----
+//
+// keys in range are deleted by a single range tombstone record,
+// the record is replayed at startup and deletes keys written before it
+
+// delete all keys in [start, end) atomically, empty end means no upper bound
+func (db *DB) DeleteRange(start, end []byte) error {
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return nil
+	}
+
+	return db.update(db.options.SyncWrite, func() error {
+		keys := db.keysInRange(start, end)
+		if len(keys) == 0 {
+			return nil
+		}
+
+		logRecord := &data.LogRecord{
+			Key:   logRecordKeyWithSeq(start, nonTxnSeqno),
+			Value: end,
+			Type:  data.LogRecordRangeDelete,
+		}
+
+		pos, err := db.appendLogRecord(logRecord)
+		if err != nil {
+			return err
+		}
+
+		db.reclaimSize += int64(pos.Size)
+		return db.deleteIndexKeys(keys)
+	})
+}
+
+// delete all keys with prefix atomically
+func (db *DB) DeletePrefix(prefix []byte) error {
+	return db.DeleteRange(prefix, prefixEnd(prefix))
+}
+
+// smallest key greater than all keys with prefix, nil if there is no such key
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// return keys in [start, end) of index, iterator is closed before
+// return, so index can be modified with them
+// caller must hold db.mu
+func (db *DB) keysInRange(start, end []byte) [][]byte {
+	var keys [][]byte
+
+	iter := db.index.Iterator(false)
+	defer iter.Close()
+	for iter.Seek(start); iter.Valid(); iter.Next() {
+		if len(end) > 0 && bytes.Compare(iter.Key(), end) >= 0 {
+			break
+		}
+		keys = append(keys, iter.Key())
+	}
+
+	return keys
+}
+
+// delete keys in [start, end) from index
+// caller must hold db.mu
+func (db *DB) deleteIndexRange(start, end []byte) error {
+	return db.deleteIndexKeys(db.keysInRange(start, end))
+}
+
+// caller must hold db.mu
+func (db *DB) deleteIndexKeys(keys [][]byte) error {
+	for _, key := range keys {
+		oldPos, err := db.index.Delete(key)
+		if err != nil {
+			return err
+		}
+		if oldPos != nil {
+			db.reclaimSize += int64(oldPos.Size)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/index"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("user;"), prefixEnd([]byte("user:")))
+	assert.Equal(t, []byte{'a', 0x01}, prefixEnd([]byte{'a', 0x00, 0xff}))
+	assert.Nil(t, prefixEnd([]byte{0xff, 0xff}))
+	assert.Nil(t, prefixEnd(nil))
+}
+
+func TestDB_DeleteRange(t *testing.T) {
+	indexTypes := []index.IndexType{index.BTREE, index.RBTREE, index.ARTREE, index.BPLUSTREE, index.HASH}
+	for _, indexType := range indexTypes {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-delete-range")
+		opts.DirPath = dir
+		opts.Maxsize = 16 * 1024
+		opts.Index = indexType
+		db, err := OpenDB(opts)
+		assert.Nil(t, err)
+
+		for i := 0; i < 200; i++ {
+			assert.Nil(t, db.Put([]byte(fmt.Sprintf("user:%03d", i)), []byte("user")))
+			assert.Nil(t, db.Put([]byte(fmt.Sprintf("order:%03d", i)), []byte("order")))
+		}
+
+		// nothing is appended if no key is in range
+		writeOff := db.activeFile.WriteOff
+		assert.Nil(t, db.DeletePrefix([]byte("item:")))
+		assert.Equal(t, writeOff, db.activeFile.WriteOff)
+
+		assert.Nil(t, db.DeletePrefix([]byte("user:")))
+		assert.Nil(t, db.DeleteRange([]byte("order:050"), []byte("order:150")))
+		// keys put after range delete are kept
+		assert.Nil(t, db.Put([]byte("user:007"), []byte("new-user")))
+
+		check := func(db *DB) {
+			size, err := db.index.Size()
+			assert.Nil(t, err)
+			assert.Equal(t, 101, size)
+			for i := 0; i < 200; i++ {
+				val, err := db.Get([]byte(fmt.Sprintf("order:%03d", i)))
+				if i >= 50 && i < 150 {
+					assert.Equal(t, ErrKeyNotFound, err)
+				} else {
+					assert.Equal(t, []byte("order"), val)
+				}
+			}
+			val, err := db.Get([]byte("user:007"))
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("new-user"), val)
+		}
+		check(db)
+
+		// range tombstone is replayed at startup
+		assert.Nil(t, db.Close())
+		db, err = OpenDB(opts)
+		assert.Nil(t, err)
+		check(db)
+
+		assert.Nil(t, db.Merge())
+		assert.Nil(t, db.Close())
+		db, err = OpenDB(opts)
+		assert.Nil(t, err)
+		check(db)
+
+		destroyDB(db)
+	}
+}
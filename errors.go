@@ -8,9 +8,20 @@ var (
 	ErrKeyNotFound            = errors.New("key not found")
 	ErrDataFileNotFound       = errors.New("data file not found")
 	ErrDataDirectoryCorrupted = errors.New("the database dir may be corrupted")
+	ErrDataFileCorrupted      = errors.New("the data file is corrupted")
 	ErrExceedMaxBatch         = errors.New("exceed the max batch size")
 	ErrMergeIsPorgress        = errors.New("merge is in progres, try merge later")
 	ErrDataBaseIsUsing        = errors.New("other porcess is using data base")
 	ErrMergeRationUnreached   = errors.New("the merge ration has not reach threshold")
 	ErrNoEnoughSpaceForMerge  = errors.New("no enough disl space for merge")
+	ErrMergeFilesOverflow     = errors.New("merged files exceed reserved file ids, try merge later")
+	ErrReadOnly               = errors.New("database is opened in read-only mode")
+	ErrInMemory               = errors.New("operation is unsupported by in-memory database")
+	ErrTxnConflict            = errors.New("transaction conflict, keys read by it have been modified")
+	ErrTxnClosed              = errors.New("transaction has been committed or aborted")
+	ErrInvalidTTL             = errors.New("ttl must be greater than 0")
+	ErrConditionFailed        = errors.New("condition of write batch doesn't hold")
+	ErrValueNotNumber         = errors.New("value is not a number or out of range")
+	ErrIncrOverflow           = errors.New("increment or decrement would overflow")
+	ErrSyncFailed             = errors.New("fsync of synchronous write failed, reopen database to recover")
 )
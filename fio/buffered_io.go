@@ -0,0 +1,201 @@
+package fio
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	bufferedIOSize          = 64 * 1024              // buffer is flushed when it's full
+	bufferedIOFlushInterval = 100 * time.Millisecond // or it has been kept for a while
+)
+
+// buffered file io, appends are kept in user space and written to file by one
+// write when buffer is full, flush interval passed, Sync or Close is called.
+// reads of buffered offsets are served from buffer
+type BufferedFileIO struct {
+	mu       *sync.RWMutex
+	fio      *FileIO
+	fileSize int64  // bytes have been written to file
+	buf      []byte // bytes after fileSize
+	timer    *time.Timer
+	flushErr error // error of flush by timer, returned by next write or sync
+}
+
+func NewBufferedFileIOManager(filename string) (*BufferedFileIO, error) {
+	fio, err := NewFileIOManager(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := fio.Size()
+	if err != nil {
+		_ = fio.Close()
+		return nil, err
+	}
+
+	return &BufferedFileIO{
+		mu:       new(sync.RWMutex),
+		fio:      fio,
+		fileSize: size,
+		buf:      make([]byte, 0, bufferedIOSize),
+	}, nil
+}
+
+func (bio *BufferedFileIO) Read(b []byte, offset int64) (int, error) {
+	bio.mu.RLock()
+	defer bio.mu.RUnlock()
+
+	var n int
+	if offset < bio.fileSize {
+		end := offset + int64(len(b))
+		if end > bio.fileSize {
+			end = bio.fileSize
+		}
+		m, err := bio.fio.Read(b[:end-offset], offset)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if n < len(b) {
+		bufOffset := offset + int64(n) - bio.fileSize
+		if bufOffset < int64(len(bio.buf)) {
+			n += copy(b[n:], bio.buf[bufOffset:])
+		}
+	}
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// append b to buffer, buffer is flushed if it's full,
+// a timer is started to flush buffer which isn't full.
+// if flush fails, bytes of b which haven't been written to file are dropped
+func (bio *BufferedFileIO) Write(b []byte) (int, error) {
+	bio.mu.Lock()
+	defer bio.mu.Unlock()
+
+	if err := bio.takeFlushErr(); err != nil {
+		return 0, err
+	}
+
+	bio.buf = append(bio.buf, b...)
+	if len(bio.buf) >= bufferedIOSize {
+		if err := bio.flush(); err != nil {
+			if len(bio.buf) >= len(b) {
+				bio.buf = bio.buf[:len(bio.buf)-len(b)]
+				return 0, err
+			}
+			n := len(b) - len(bio.buf)
+			bio.buf = bio.buf[:0]
+			return n, err
+		}
+	} else if bio.timer == nil {
+		bio.timer = time.AfterFunc(bufferedIOFlushInterval, bio.flushByTimer)
+	}
+
+	return len(b), nil
+}
+
+// flush buffer and sync file to disk
+func (bio *BufferedFileIO) Sync() error {
+	bio.mu.Lock()
+	defer bio.mu.Unlock()
+
+	if err := bio.takeFlushErr(); err != nil {
+		return err
+	}
+	if err := bio.flush(); err != nil {
+		return err
+	}
+
+	return bio.fio.Sync()
+}
+
+func (bio *BufferedFileIO) Close() error {
+	bio.mu.Lock()
+	defer bio.mu.Unlock()
+
+	flushErr := bio.takeFlushErr()
+	if err := bio.flush(); err != nil && flushErr == nil {
+		flushErr = err
+	}
+
+	if err := bio.fio.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+// size of file including buffered bytes
+func (bio *BufferedFileIO) Size() (int64, error) {
+	bio.mu.RLock()
+	defer bio.mu.RUnlock()
+
+	return bio.fileSize + int64(len(bio.buf)), nil
+}
+
+// buffered bytes after size are dropped, file is truncated if size is before them
+func (bio *BufferedFileIO) Truncate(size int64) error {
+	bio.mu.Lock()
+	defer bio.mu.Unlock()
+
+	if size >= bio.fileSize {
+		if size-bio.fileSize < int64(len(bio.buf)) {
+			bio.buf = bio.buf[:size-bio.fileSize]
+		}
+		return nil
+	}
+
+	bio.buf = bio.buf[:0]
+	if err := bio.fio.Truncate(size); err != nil {
+		return err
+	}
+	bio.fileSize = size
+
+	return nil
+}
+
+func (bio *BufferedFileIO) flushByTimer() {
+	bio.mu.Lock()
+	defer bio.mu.Unlock()
+
+	bio.timer = nil
+	if err := bio.flush(); err != nil && bio.flushErr == nil {
+		bio.flushErr = err
+	}
+}
+
+// write buffer to file, caller must hold bio.mu
+func (bio *BufferedFileIO) flush() error {
+	if bio.timer != nil {
+		bio.timer.Stop()
+		bio.timer = nil
+	}
+	if len(bio.buf) == 0 {
+		return nil
+	}
+
+	n, err := bio.fio.Write(bio.buf)
+	bio.fileSize += int64(n)
+	bio.buf = bio.buf[:copy(bio.buf, bio.buf[n:])]
+
+	// buffer may be grown by a large write
+	if len(bio.buf) == 0 && cap(bio.buf) > bufferedIOSize {
+		bio.buf = make([]byte, 0, bufferedIOSize)
+	}
+
+	return err
+}
+
+// caller must hold bio.mu
+func (bio *BufferedFileIO) takeFlushErr() error {
+	err := bio.flushErr
+	bio.flushErr = nil
+	return err
+}
@@ -0,0 +1,71 @@
+package fio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferedFileIO_Write(t *testing.T) {
+	path := filepath.Join("/tmp", "buffered-io.data")
+	defer destroyFile(path)
+
+	bio, err := NewBufferedFileIOManager(path)
+	assert.Nil(t, err)
+
+	n, err := bio.Write([]byte("bitcask kv"))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, n)
+	_, err = bio.Write([]byte(" storage"))
+	assert.Nil(t, err)
+
+	// buffered bytes are readable but not written to file
+	size, err := bio.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(18), size)
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), stat.Size())
+
+	b := make([]byte, 10)
+	_, err = bio.Read(b, 9)
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, bio.Sync())
+
+	stat, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(18), stat.Size())
+
+	// read across flushed bytes and buffer
+	_, err = bio.Write([]byte(" engine"))
+	assert.Nil(t, err)
+	n, err = bio.Read(b, 12)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("torage eng"), b[:n])
+
+	// buffer is flushed by timer
+	time.Sleep(3 * bufferedIOFlushInterval)
+	stat, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25), stat.Size())
+
+	// full buffer is flushed by write
+	large := bytes.Repeat([]byte("a"), bufferedIOSize)
+	_, err = bio.Write(large)
+	assert.Nil(t, err)
+	stat, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25+bufferedIOSize), stat.Size())
+
+	_, err = bio.Write([]byte("tail"))
+	assert.Nil(t, err)
+	assert.Nil(t, bio.Close())
+	stat, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(29+bufferedIOSize), stat.Size())
+}
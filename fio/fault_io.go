@@ -0,0 +1,241 @@
+package fio
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+var (
+	ErrInjectedFault = errors.New("injected io fault")
+	ErrInjectedCrash = errors.New("file is unavailable after injected crash")
+)
+
+// ---- fault injection for crash testing ----
+//
+// FaultInjector.Wrap is set to Options.IOWrapper of a db, every file opened by
+// the db (including files of its merge db) is wrapped by FaultFileIO and faults
+// are configured on the injector. Crash drops bytes which haven't been synced,
+// it's only meaningful for StandFileIO and BufferedIO
+
+// FaultInjector controls faults of files wrapped by it
+type FaultInjector struct {
+	mu           *sync.Mutex
+	writeBudget  int64 // bytes can be written before writes fail, -1 means unlimited
+	failSync     bool
+	shortReads   bool
+	corruptReads bool
+	synced       map[string]int64 // size of file at last sync
+	files        map[*FaultFileIO]struct{}
+}
+
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		mu:          new(sync.Mutex),
+		writeBudget: -1,
+		synced:      make(map[string]int64),
+		files:       make(map[*FaultFileIO]struct{}),
+	}
+}
+
+// writes fail after n more bytes are written, the write crossing the limit
+// is partially done. n < 0 disables it
+func (fi *FaultInjector) FailWritesAfter(n int64) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.writeBudget = n
+}
+
+func (fi *FaultInjector) FailSync(fail bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.failSync = fail
+}
+
+// reads return half of requested bytes with ErrInjectedFault
+func (fi *FaultInjector) ShortReads(short bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.shortReads = short
+}
+
+// last byte of every read is flipped
+func (fi *FaultInjector) CorruptReads(corrupt bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.corruptReads = corrupt
+}
+
+// clear all faults
+func (fi *FaultInjector) Reset() {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.reset()
+}
+
+// simulate a crash, files opened before it become unavailable except Close,
+// bytes written after last sync of each file are dropped, faults are cleared
+// so db can be opened again
+func (fi *FaultInjector) Crash() error {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	for file := range fi.files {
+		file.crashed = true
+	}
+	fi.files = make(map[*FaultFileIO]struct{})
+
+	for name, size := range fi.synced {
+		stat, err := os.Stat(name)
+		if os.IsNotExist(err) {
+			// file has been removed or renamed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if stat.Size() > size {
+			if err := os.Truncate(name, size); err != nil {
+				return err
+			}
+		}
+	}
+	fi.synced = make(map[string]int64)
+	fi.reset()
+
+	return nil
+}
+
+// caller must hold fi.mu
+func (fi *FaultInjector) reset() {
+	fi.writeBudget = -1
+	fi.failSync = false
+	fi.shortReads = false
+	fi.corruptReads = false
+}
+
+// io manager wrapped by fault injector
+type FaultFileIO struct {
+	IOManager
+	name     string
+	injector *FaultInjector
+	crashed  bool
+}
+
+// wrap manager by fault injector, it's an IOManagerWrapper
+func (fi *FaultInjector) Wrap(filename string, manager IOManager) (IOManager, error) {
+	size, err := manager.Size()
+	if err != nil {
+		_ = manager.Close()
+		return nil, err
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	// file reopened may still have bytes which haven't been synced
+	if synced, ok := fi.synced[filename]; !ok || size < synced {
+		fi.synced[filename] = size
+	}
+	file := &FaultFileIO{IOManager: manager, name: filename, injector: fi}
+	fi.files[file] = struct{}{}
+
+	return file, nil
+}
+
+func (fio *FaultFileIO) Read(b []byte, offset int64) (int, error) {
+	fi := fio.injector
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fio.crashed {
+		return 0, ErrInjectedCrash
+	}
+	if fi.shortReads && len(b) > 1 {
+		n, _ := fio.IOManager.Read(b[:len(b)/2], offset)
+		return n, ErrInjectedFault
+	}
+
+	n, err := fio.IOManager.Read(b, offset)
+	if fi.corruptReads && n > 0 {
+		b[n-1] ^= 0xff
+	}
+	return n, err
+}
+
+func (fio *FaultFileIO) Write(b []byte) (int, error) {
+	fi := fio.injector
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fio.crashed {
+		return 0, ErrInjectedCrash
+	}
+	if fi.writeBudget >= 0 && fi.writeBudget < int64(len(b)) {
+		n, _ := fio.IOManager.Write(b[:fi.writeBudget])
+		fi.writeBudget = 0
+		return n, ErrInjectedFault
+	}
+	if fi.writeBudget >= 0 {
+		fi.writeBudget -= int64(len(b))
+	}
+
+	return fio.IOManager.Write(b)
+}
+
+func (fio *FaultFileIO) Sync() error {
+	fi := fio.injector
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fio.crashed {
+		return ErrInjectedCrash
+	}
+	if fi.failSync {
+		return ErrInjectedFault
+	}
+
+	if err := fio.IOManager.Sync(); err != nil {
+		return err
+	}
+	size, err := fio.IOManager.Size()
+	if err != nil {
+		return err
+	}
+	fi.synced[fio.name] = size
+
+	return nil
+}
+
+func (fio *FaultFileIO) Truncate(size int64) error {
+	fi := fio.injector
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fio.crashed {
+		return ErrInjectedCrash
+	}
+	if err := fio.IOManager.Truncate(size); err != nil {
+		return err
+	}
+	if fi.synced[fio.name] > size {
+		fi.synced[fio.name] = size
+	}
+
+	return nil
+}
+
+// file is always closed, even after crash
+func (fio *FaultFileIO) Close() error {
+	fi := fio.injector
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fio.crashed {
+		_ = fio.IOManager.Close()
+		return nil
+	}
+	delete(fi.files, fio)
+
+	return fio.IOManager.Close()
+}
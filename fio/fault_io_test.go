@@ -0,0 +1,61 @@
+package fio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultFileIO(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-fault")
+	defer destroyFile(dir)
+	fi := NewFaultInjector()
+
+	path := filepath.Join(dir, "a.data")
+	manager, err := NewIOManager(path, StandFileIO)
+	assert.Nil(t, err)
+	fio, err := fi.Wrap(path, manager)
+	assert.Nil(t, err)
+
+	_, err = fio.Write([]byte("bitcask kv"))
+	assert.Nil(t, err)
+	assert.Nil(t, fio.Sync())
+
+	// write crossing the limit is partially done
+	fi.FailWritesAfter(3)
+	n, err := fio.Write([]byte(" storage"))
+	assert.Equal(t, ErrInjectedFault, err)
+	assert.Equal(t, 3, n)
+	_, err = fio.Write([]byte("engine"))
+	assert.Equal(t, ErrInjectedFault, err)
+	fi.FailWritesAfter(-1)
+
+	fi.FailSync(true)
+	assert.Equal(t, ErrInjectedFault, fio.Sync())
+	fi.FailSync(false)
+
+	b := make([]byte, 10)
+	fi.ShortReads(true)
+	n, err = fio.Read(b, 0)
+	assert.Equal(t, ErrInjectedFault, err)
+	assert.Equal(t, 5, n)
+	fi.ShortReads(false)
+
+	fi.CorruptReads(true)
+	_, err = fio.Read(b, 0)
+	assert.Nil(t, err)
+	assert.NotEqual(t, []byte("bitcask kv"), b)
+	fi.CorruptReads(false)
+
+	// bytes after last sync are dropped by crash
+	assert.Nil(t, fi.Crash())
+	_, err = fio.Write([]byte("engine"))
+	assert.Equal(t, ErrInjectedCrash, err)
+	assert.Nil(t, fio.Close())
+
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), stat.Size())
+}
@@ -21,6 +21,15 @@ func NewFileIOManager(filename string) (*FileIO, error) {
 	return &FileIO{file: file}, nil
 }
 
+// open existing file without write permission, writes fail
+func NewReadOnlyFileIOManager(filename string) (*FileIO, error) {
+	file, err := os.OpenFile(filename, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &FileIO{file: file}, nil
+}
+
 func (fio *FileIO) Read(data []byte, off int64) (int, error) {
 	return fio.file.ReadAt(data, off)
 }
@@ -37,6 +46,11 @@ func (fio *FileIO) Close() error {
 	return fio.file.Close()
 }
 
+// file is opened with O_APPEND, so next write starts at size
+func (fio *FileIO) Truncate(size int64) error {
+	return fio.file.Truncate(size)
+}
+
 func (fio *FileIO) Size() (int64, error) {
 	stat, err := fio.file.Stat()
 	if err != nil {
@@ -92,3 +92,28 @@ func TestFileIO_Close(t *testing.T) {
 	err = fio.Close()
 	assert.Nil(t, err)
 }
+
+func TestFileIO_ReadOnly(t *testing.T) {
+	path := filepath.Join("/tmp", "a.data")
+	_, err := NewReadOnlyFileIOManager(path)
+	assert.True(t, os.IsNotExist(err))
+
+	fio, err := NewFileIOManager(path)
+	defer destroyFile(path)
+	assert.Nil(t, err)
+	_, err = fio.Write([]byte("key-a"))
+	assert.Nil(t, err)
+	assert.Nil(t, fio.Close())
+
+	roFio, err := NewReadOnlyFileIOManager(path)
+	assert.Nil(t, err)
+	defer roFio.Close()
+
+	b := make([]byte, 5)
+	_, err = roFio.Read(b, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("key-a"), b)
+
+	_, err = roFio.Write([]byte("key-b"))
+	assert.NotNil(t, err)
+}
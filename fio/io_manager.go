@@ -1,10 +1,18 @@
 package fio
 
+import "errors"
+
 type FileIOType = byte
 
+var ErrUnsupportedIOType = errors.New("unsupported io type")
+
 const (
-	StandFileIO FileIOType = iota
-	MemoryMapIO
+	StandFileIO         FileIOType = iota
+	MemoryMapIO                    // read-only mmap, used to load files at startup
+	WritableMemoryMapIO            // mmap supports appends
+	BufferedIO                     // appends are buffered in memory until flush
+	MemoryIO                       // bytes are kept in memory only
+	ReadOnlyFileIO                 // standard file io without write permission, used by read-only db
 )
 
 type IOManager interface {
@@ -22,8 +30,15 @@ type IOManager interface {
 
 	// file size
 	Size() (int64, error)
+
+	// drop bytes after size, e.g. bytes of a failed write
+	Truncate(int64) error
 }
 
+// wrap io manager of a file opened by db, e.g. to inject faults in tests,
+// wrapper must close manager if it fails
+type IOManagerWrapper func(filename string, manager IOManager) (IOManager, error)
+
 //
 func NewIOManager(filename string, ioType FileIOType) (IOManager, error) {
 	switch ioType {
@@ -31,7 +46,15 @@ func NewIOManager(filename string, ioType FileIOType) (IOManager, error) {
 		return NewFileIOManager(filename)
 	case MemoryMapIO:
 		return NewMMapIOManager(filename)
+	case WritableMemoryMapIO:
+		return NewWritableMMapIOManager(filename)
+	case BufferedIO:
+		return NewBufferedFileIOManager(filename)
+	case MemoryIO:
+		return NewMemoryIOManager(filename)
+	case ReadOnlyFileIO:
+		return NewReadOnlyFileIOManager(filename)
 	default:
-		panic("unsupport io type")
+		return nil, ErrUnsupportedIOType
 	}
 }
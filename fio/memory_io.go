@@ -0,0 +1,70 @@
+package fio
+
+import (
+	"io"
+	"sync"
+)
+
+// in-memory file io, bytes are never written to disk,
+// each manager owns its own buffer, file name is ignored
+type MemoryFileIO struct {
+	mu   *sync.RWMutex
+	data []byte
+}
+
+func NewMemoryIOManager(filename string) (*MemoryFileIO, error) {
+	return &MemoryFileIO{mu: new(sync.RWMutex)}, nil
+}
+
+func (mio *MemoryFileIO) Read(b []byte, offset int64) (int, error) {
+	mio.mu.RLock()
+	defer mio.mu.RUnlock()
+
+	if offset >= int64(len(mio.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(b, mio.data[offset:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (mio *MemoryFileIO) Write(b []byte) (int, error) {
+	mio.mu.Lock()
+	defer mio.mu.Unlock()
+
+	mio.data = append(mio.data, b...)
+	return len(b), nil
+}
+
+func (mio *MemoryFileIO) Sync() error {
+	return nil
+}
+
+// release buffer
+func (mio *MemoryFileIO) Close() error {
+	mio.mu.Lock()
+	defer mio.mu.Unlock()
+
+	mio.data = nil
+	return nil
+}
+
+func (mio *MemoryFileIO) Truncate(size int64) error {
+	mio.mu.Lock()
+	defer mio.mu.Unlock()
+
+	if size < int64(len(mio.data)) {
+		mio.data = mio.data[:size]
+	}
+	return nil
+}
+
+func (mio *MemoryFileIO) Size() (int64, error) {
+	mio.mu.RLock()
+	defer mio.mu.RUnlock()
+
+	return int64(len(mio.data)), nil
+}
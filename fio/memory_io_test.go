@@ -0,0 +1,44 @@
+package fio
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryFileIO(t *testing.T) {
+	mio, err := NewMemoryIOManager("/nonexistent/memory.data")
+	assert.Nil(t, err)
+
+	_, err = mio.Write([]byte("bitcask kv"))
+	assert.Nil(t, err)
+	_, err = mio.Write([]byte(" storage"))
+	assert.Nil(t, err)
+
+	size, err := mio.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(18), size)
+
+	b := make([]byte, 7)
+	n, err := mio.Read(b, 8)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("kv stor"), b[:n])
+
+	n, err = mio.Read(b, 14)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []byte("rage"), b[:n])
+
+	_, err = mio.Read(b, 18)
+	assert.Equal(t, io.EOF, err)
+
+	// other manager doesn't share buffer
+	other, err := NewMemoryIOManager("/nonexistent/memory.data")
+	assert.Nil(t, err)
+	size, err = other.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), size)
+
+	assert.Nil(t, mio.Sync())
+	assert.Nil(t, mio.Close())
+}
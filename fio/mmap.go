@@ -1,11 +1,14 @@
 package fio
 
 import (
+	"errors"
 	"os"
 
 	"golang.org/x/exp/mmap"
 )
 
+var ErrReadOnlyMMap = errors.New("mmap io is read-only")
+
 // mmap io,
 type MMap struct {
 	readAt *mmap.ReaderAt
@@ -29,17 +32,22 @@ func (mmap *MMap) Read(b []byte, offset int64) (int, error) {
 }
 
 func (mmap *MMap) Write([]byte) (int, error) {
-	panic("mmap write not implement")
+	return 0, ErrReadOnlyMMap
 }
 
+// mapping is read-only, nothing to sync
 func (mmap *MMap) Sync() error {
-	return mmap.readAt.Close()
+	return nil
 }
 
 func (mmap *MMap) Close() error {
 	return mmap.readAt.Close()
 }
 
+func (mmap *MMap) Truncate(int64) error {
+	return ErrReadOnlyMMap
+}
+
 func (mmap *MMap) Size() (int64, error) {
 	return int64(mmap.readAt.Len()), nil
 }
@@ -36,4 +36,8 @@ func TestMMap_Basic(t *testing.T) {
 	log.Printf("n: %v", n)
 	log.Printf("err: %v", err)
 
+	n, err = mmapIO2.Write([]byte("world"))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, ErrReadOnlyMMap, err)
+	assert.Equal(t, ErrReadOnlyMMap, mmapIO2.Truncate(0))
 }
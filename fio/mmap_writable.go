@@ -0,0 +1,198 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package fio
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	mmapMinGrowSize = 64 * 1024        // file is grown by at least 64KB
+	mmapMaxGrowSize = 64 * 1024 * 1024 // and at most 64MB once
+)
+
+// writable mmap io, file is pre-grown and remapped when appended data exceeds mapping,
+// size is the logical length of written data, file is truncated to it at close
+type WritableMMap struct {
+	mu    *sync.RWMutex
+	file  *os.File
+	data  []byte // mapped region of file
+	size  int64  // logical size of file
+	grown bool   // file length has been changed since last sync
+}
+
+func NewWritableMMapIOManager(filename string) (*WritableMMap, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, FileDataPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	wm := &WritableMMap{mu: new(sync.RWMutex), file: file, size: stat.Size()}
+	if wm.size > 0 {
+		if err := wm.remap(wm.size); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+
+	return wm, nil
+}
+
+func (wm *WritableMMap) Read(b []byte, offset int64) (int, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	if offset >= wm.size {
+		return 0, io.EOF
+	}
+
+	n := copy(b, wm.data[offset:wm.size])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// append b to the end of logical data, file is grown if mapping is full
+func (wm *WritableMMap) Write(b []byte) (int, error) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if wm.size+int64(len(b)) > int64(len(wm.data)) {
+		if err := wm.grow(wm.size + int64(len(b))); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(wm.data[wm.size:], b)
+	wm.size += int64(n)
+	return n, nil
+}
+
+// flush mapped pages to disk, file metadata is synced if file has been grown
+func (wm *WritableMMap) Sync() error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if len(wm.data) > 0 {
+		if err := unix.Msync(wm.data, unix.MS_SYNC); err != nil {
+			return err
+		}
+	}
+
+	if wm.grown {
+		if err := wm.file.Sync(); err != nil {
+			return err
+		}
+		wm.grown = false
+	}
+
+	return nil
+}
+
+// unmap file and drop pre-grown space after logical data
+func (wm *WritableMMap) Close() error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if wm.data != nil {
+		if err := unix.Msync(wm.data, unix.MS_SYNC); err != nil {
+			return err
+		}
+		if err := unix.Munmap(wm.data); err != nil {
+			return err
+		}
+		wm.data = nil
+	}
+
+	if err := wm.file.Truncate(wm.size); err != nil {
+		return err
+	}
+
+	return wm.file.Close()
+}
+
+// logical size of file, pre-grown space isn't included
+func (wm *WritableMMap) Size() (int64, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.size, nil
+}
+
+// drop data after size, file is truncated to size so pre-grown space is dropped too
+func (wm *WritableMMap) Truncate(size int64) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if wm.data != nil {
+		if err := unix.Munmap(wm.data); err != nil {
+			return err
+		}
+		wm.data = nil
+	}
+
+	if err := wm.file.Truncate(size); err != nil {
+		return err
+	}
+	wm.grown = true
+	wm.size = size
+
+	if size > 0 {
+		return wm.remap(size)
+	}
+	return nil
+}
+
+// grow file to hold at least n bytes and remap it
+// caller must hold wm.mu
+func (wm *WritableMMap) grow(n int64) error {
+	step := int64(len(wm.data))
+	if step < mmapMinGrowSize {
+		step = mmapMinGrowSize
+	}
+	if step > mmapMaxGrowSize {
+		step = mmapMaxGrowSize
+	}
+
+	newSize := int64(len(wm.data)) + step
+	if newSize < n {
+		newSize = n
+	}
+
+	if err := wm.file.Truncate(newSize); err != nil {
+		return err
+	}
+	wm.grown = true
+
+	return wm.remap(newSize)
+}
+
+// caller must hold wm.mu
+func (wm *WritableMMap) remap(n int64) error {
+	if wm.data != nil {
+		if err := unix.Munmap(wm.data); err != nil {
+			return err
+		}
+		wm.data = nil
+	}
+
+	data, err := unix.Mmap(int(wm.file.Fd()), 0, int(n), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+
+	wm.data = data
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package fio
+
+import "errors"
+
+var ErrWritableMMapUnsupported = errors.New("writable mmap is not supported on this platform")
+
+func NewWritableMMapIOManager(filename string) (IOManager, error) {
+	return nil, ErrWritableMMapUnsupported
+}
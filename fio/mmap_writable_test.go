@@ -0,0 +1,93 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package fio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritableMMap_Write(t *testing.T) {
+	path := filepath.Join("/tmp", "mmap-writable.data")
+	defer destroyFile(path)
+
+	wm, err := NewWritableMMapIOManager(path)
+	assert.Nil(t, err)
+
+	size, err := wm.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), size)
+
+	// writes exceed initial mapping and file is remapped
+	chunk := bytes.Repeat([]byte("bitcask-go"), 1000)
+	for i := 0; i < 20; i++ {
+		n, err := wm.Write(chunk)
+		assert.Nil(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.Nil(t, wm.Sync())
+
+	size, err = wm.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20*len(chunk)), size)
+
+	// file is pre-grown, but reads stop at logical size
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Greater(t, stat.Size(), size)
+
+	b := make([]byte, len(chunk))
+	n, err := wm.Read(b, int64(19*len(chunk)))
+	assert.Nil(t, err)
+	assert.Equal(t, chunk, b[:n])
+	n, err = wm.Read(b, size-5)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 5, n)
+
+	// file is truncated to logical size at close
+	assert.Nil(t, wm.Close())
+	stat, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, size, stat.Size())
+
+	wm, err = NewWritableMMapIOManager(path)
+	assert.Nil(t, err)
+	defer wm.Close()
+	_, err = wm.Write([]byte("tail"))
+	assert.Nil(t, err)
+	b = make([]byte, 4)
+	_, err = wm.Read(b, size)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("tail"), b)
+}
+
+func TestWritableMMap_Truncate(t *testing.T) {
+	path := filepath.Join("/tmp", "mmap-writable-truncate.data")
+	defer destroyFile(path)
+
+	wm, err := NewWritableMMapIOManager(path)
+	assert.Nil(t, err)
+	defer wm.Close()
+
+	_, err = wm.Write([]byte("bitcask kv storage"))
+	assert.Nil(t, err)
+
+	// pre-grown space is dropped with bytes after size
+	assert.Nil(t, wm.Truncate(10))
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), stat.Size())
+
+	_, err = wm.Write([]byte(" engine"))
+	assert.Nil(t, err)
+	b := make([]byte, 17)
+	_, err = wm.Read(b, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("bitcask kv engine"), b)
+}
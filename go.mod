@@ -3,19 +3,23 @@ module bitcask-go
 go 1.17
 
 require (
-	github.com/HuKeping/rbtree v1.0.1 // indirect
+	github.com/HuKeping/rbtree v1.0.1
+	github.com/gofrs/flock v0.8.1
+	github.com/google/btree v1.1.2
+	github.com/plar/go-adaptive-radix-tree v1.0.5
+	github.com/sirupsen/logrus v1.9.3
+	github.com/stretchr/testify v1.8.4
+	github.com/tidwall/redcon v1.6.2
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/exp v0.0.0-20230905200255-921286631fa9
+	golang.org/x/sys v0.12.0
+)
+
+require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/gofrs/flock v0.8.1 // indirect
-	github.com/google/btree v1.1.2 // indirect
-	github.com/plar/go-adaptive-radix-tree v1.0.5 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/sirupsen/logrus v1.9.3 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
 	github.com/tidwall/btree v1.1.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
-	github.com/tidwall/redcon v1.6.2 // indirect
-	golang.org/x/exp v0.0.0-20230905200255-921286631fa9 // indirect
-	golang.org/x/sys v0.12.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
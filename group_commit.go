@@ -0,0 +1,134 @@
+package bitcaskgo
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ---- group commit of synchronous writes ----
+//
+// writers append records under db.mu and release it before fsync,
+// then one of waiting writers becomes leader and syncs active file for
+// everyone who has appended before it, others wait for result of the leader.
+// leader doesn't hold db.mu during fsync, so reads and writes go on meanwhile
+
+type groupCommit struct {
+	mu       *sync.Mutex
+	cond     *sync.Cond
+	appended uint64 // sequence of last appended write
+	synced   uint64 // sequence of last persisted write
+	syncing  bool   // leader is syncing
+
+	// referenced shared by leader while it syncs a file without db.mu,
+	// data files are closed under it exclusively
+	fileRefs *sync.RWMutex
+}
+
+func newGroupCommit() *groupCommit {
+	gc := &groupCommit{mu: new(sync.Mutex), fileRefs: new(sync.RWMutex)}
+	gc.cond = sync.NewCond(gc.mu)
+	return gc
+}
+
+// register a write which has been appended to active file,
+// return sequence to wait for, caller must hold db.mu
+func (gc *groupCommit) append() uint64 {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.appended++
+	return gc.appended
+}
+
+// wait until write of seq is persisted, sync is called by leader and covers
+// all writes appended before it's called, waiters retry if leader failed
+func (gc *groupCommit) wait(seq uint64, sync func() error) error {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	for gc.synced < seq {
+		if gc.syncing {
+			gc.cond.Wait()
+			continue
+		}
+
+		// become leader
+		gc.syncing = true
+		target := gc.appended
+		gc.mu.Unlock()
+		err := sync()
+		gc.mu.Lock()
+		gc.syncing = false
+
+		if err == nil && target > gc.synced {
+			gc.synced = target
+		}
+		gc.cond.Broadcast()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run fn which appends records under db.mu, if syncWrites is true,
+// return after records are persisted by a shared fsync.
+// index is updated by fn before fsync, so records may be read before persisted.
+// if fsync fails, db rejects all reads and writes until it's reopened, so
+// records which may not be persisted are never served after the failure
+func (db *DB) update(syncWrites bool, fn func() error) error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+
+	db.mu.Lock()
+	if db.syncErr != nil {
+		db.mu.Unlock()
+		return db.syncErr
+	}
+	err := fn()
+	var syncSeq uint64
+	if err == nil && syncWrites {
+		syncSeq = db.groupCommit.append()
+	}
+	db.mu.Unlock()
+
+	if err != nil || syncSeq == 0 {
+		return err
+	}
+
+	return db.groupCommit.wait(syncSeq, db.syncActiveFile)
+}
+
+// sync active file for group commit, records in older files have been
+// persisted before rotating, so file may be rotated during fsync, but it
+// isn't closed until fsync is done. db is poisoned if it fails
+func (db *DB) syncActiveFile() error {
+	db.mu.RLock()
+	if db.syncErr != nil {
+		db.mu.RUnlock()
+		return db.syncErr
+	}
+	activeFile := db.activeFile
+	if activeFile == nil {
+		db.mu.RUnlock()
+		return nil
+	}
+	db.groupCommit.fileRefs.RLock()
+	db.mu.RUnlock()
+
+	err := activeFile.Sync()
+	db.groupCommit.fileRefs.RUnlock()
+
+	if err != nil {
+		db.mu.Lock()
+		if db.syncErr == nil {
+			db.syncErr = fmt.Errorf("%w: %v", ErrSyncFailed, err)
+		}
+		db.mu.Unlock()
+	}
+
+	return err
+}
@@ -0,0 +1,134 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/fio"
+	"bitcask-go/utils"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupCommit_Wait(t *testing.T) {
+	gc := newGroupCommit()
+	var syncs int64
+	syncFile := func() error {
+		atomic.AddInt64(&syncs, 1)
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	var appendMu sync.Mutex
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			appendMu.Lock()
+			seq := gc.append()
+			appendMu.Unlock()
+			assert.Nil(t, gc.wait(seq, syncFile))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(100), gc.synced)
+	assert.Less(t, atomic.LoadInt64(&syncs), int64(100))
+}
+
+func TestDB_SyncWriteConcurrent(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-group-commit")
+	opts.DirPath = dir
+	opts.SyncWrite = true
+	opts.Maxsize = 64 * 1024
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g * 200; i < (g+1)*200; i++ {
+				assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(1600), db.groupCommit.synced)
+
+	assert.Nil(t, db.Close())
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	for i := 0; i < 1600; i++ {
+		val, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+	assert.Nil(t, db2.Close())
+}
+
+// io manager whose next sync blocks until released once it's armed
+type blockingSyncIO struct {
+	fio.IOManager
+	armed   *int32
+	syncing chan struct{}
+	release chan struct{}
+}
+
+func (bio *blockingSyncIO) Sync() error {
+	if atomic.CompareAndSwapInt32(bio.armed, 1, 0) {
+		bio.syncing <- struct{}{}
+		<-bio.release
+	}
+	return bio.IOManager.Sync()
+}
+
+func TestDB_SyncWriteDoesntBlockReads(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-group-commit-reads")
+	opts.DirPath = dir
+	opts.SyncWrite = true
+
+	var armed int32
+	syncing := make(chan struct{}, 1)
+	release := make(chan struct{})
+	opts.IOWrapper = func(filename string, manager fio.IOManager) (fio.IOManager, error) {
+		return &blockingSyncIO{IOManager: manager, armed: &armed, syncing: syncing, release: release}, nil
+	}
+
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put(utils.GetTestKey(1), utils.GetTestValue(1, 1)))
+
+	// first sync write blocks in fsync
+	atomic.StoreInt32(&armed, 1)
+	done := make(chan error)
+	go func() {
+		done <- db.Put(utils.GetTestKey(2), utils.GetTestValue(2, 1))
+	}()
+	<-syncing
+
+	// reads and appends go on while leader is syncing
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(1, 1), val)
+	db.mu.Lock()
+	_, err = db.appendLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq(utils.GetTestKey(3), nonTxnSeqno),
+		Value: utils.GetTestValue(3, 1),
+	})
+	db.mu.Unlock()
+	assert.Nil(t, err)
+
+	close(release)
+	assert.Nil(t, <-done)
+}
@@ -0,0 +1,99 @@
+package bitcaskgo
+
+import (
+	"math"
+	"strconv"
+)
+
+// ---- atomic numeric increment ----
+//
+// value is read, increased and written under db.mu, numbers are stored as
+// decimal strings, e.g. "-12" and "3.5", missing key is treated as 0, ttl of key is kept
+
+// add delta to integer value of key, return new value
+func (db *DB) Incr(key []byte, delta int64) (int64, error) {
+	var result int64
+	err := db.readModifyWrite(key, func(value []byte, exists bool) ([]byte, error) {
+		var n int64
+		if exists {
+			var err error
+			if n, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+				return nil, ErrValueNotNumber
+			}
+		}
+
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return nil, ErrIncrOverflow
+		}
+
+		result = n + delta
+		return []byte(strconv.FormatInt(result, 10)), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// add delta to float value of key, return new value
+func (db *DB) IncrByFloat(key []byte, delta float64) (float64, error) {
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return 0, ErrValueNotNumber
+	}
+
+	var result float64
+	err := db.readModifyWrite(key, func(value []byte, exists bool) ([]byte, error) {
+		var f float64
+		if exists {
+			var err error
+			f, err = strconv.ParseFloat(string(value), 64)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, ErrValueNotNumber
+			}
+		}
+
+		result = f + delta
+		if math.IsInf(result, 0) {
+			return nil, ErrIncrOverflow
+		}
+		return []byte(strconv.FormatFloat(result, 'f', -1, 64)), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// put value returned by fn with current value of key under db.mu,
+// expired key is treated as missing, ttl of key is kept
+func (db *DB) readModifyWrite(key []byte, fn func(value []byte, exists bool) ([]byte, error)) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	return db.update(db.options.SyncWrite, func() error {
+		var value []byte
+		var expire int64
+
+		pos, err := db.index.Get(key)
+		if err != nil {
+			return err
+		}
+		exists := pos != nil && !pos.Expired()
+		if exists {
+			if value, err = db.getValueByPostion(pos); err != nil {
+				return err
+			}
+			expire = pos.Expire
+		}
+
+		newValue, err := fn(value, exists)
+		if err != nil {
+			return err
+		}
+
+		return db.appendPut(key, newValue, expire)
+	})
+}
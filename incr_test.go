@@ -0,0 +1,94 @@
+package bitcaskgo
+
+import (
+	"math"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_Incr(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-incr")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	n, err := db.Incr([]byte("counter"), 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	n, err = db.Incr([]byte("counter"), -7)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-2), n)
+	val, err := db.Get([]byte("counter"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("-2"), val)
+
+	assert.Nil(t, db.Put([]byte("max"), []byte("9223372036854775807")))
+	_, err = db.Incr([]byte("max"), 1)
+	assert.Equal(t, ErrIncrOverflow, err)
+
+	assert.Nil(t, db.Put([]byte("name"), []byte("bitcask")))
+	_, err = db.Incr([]byte("name"), 1)
+	assert.Equal(t, ErrValueNotNumber, err)
+
+	// ttl of key is kept
+	assert.Nil(t, db.PutWithTTL([]byte("lease"), []byte("1"), time.Hour))
+	n, err = db.Incr([]byte("lease"), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	pos, err := db.index.Get([]byte("lease"))
+	assert.Nil(t, err)
+	assert.True(t, pos.Expire > 0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_, err := db.Incr([]byte("concurrent"), 1)
+				assert.Nil(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	n, err = db.Incr([]byte("concurrent"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(800), n)
+}
+
+func TestDB_IncrByFloat(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-incr")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	f, err := db.IncrByFloat([]byte("price"), 10.5)
+	assert.Nil(t, err)
+	assert.Equal(t, 10.5, f)
+	f, err = db.IncrByFloat([]byte("price"), -0.25)
+	assert.Nil(t, err)
+	assert.Equal(t, 10.25, f)
+	val, err := db.Get([]byte("price"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("10.25"), val)
+
+	// integer value can be increased by float
+	assert.Nil(t, db.Put([]byte("count"), []byte("3")))
+	f, err = db.IncrByFloat([]byte("count"), 1.5)
+	assert.Nil(t, err)
+	assert.Equal(t, 4.5, f)
+
+	_, err = db.IncrByFloat([]byte("price"), math.Inf(1))
+	assert.Equal(t, ErrValueNotNumber, err)
+	assert.Nil(t, db.Put([]byte("big"), []byte("1e308")))
+	_, err = db.IncrByFloat([]byte("big"), 1e308)
+	assert.Equal(t, ErrIncrOverflow, err)
+}
@@ -1,45 +1,148 @@
 package index
 
 import (
+	"bitcask-go/data"
+	"bytes"
+	"sort"
 	"sync"
 
-	goapt "github.com/plar/go-adaptive-radix-tree"
+	goart "github.com/plar/go-adaptive-radix-tree"
 )
 
-// TODO: complete art index methord
-
+// AdaptiveRadixTree is encapsulation of go-adaptive-radix-tree
+// keys with long shared prefix are stored in compressed path,
+// so it uses less memory than btree/rbtree for redis internal keys
+// you can see more at https://github.com/plar/go-adaptive-radix-tree
 type AdaptiveRadixTree struct {
 	mu   *sync.RWMutex
-	tree goapt.Tree
+	tree goart.Tree
 }
 
 func NewARTIndex() *AdaptiveRadixTree {
 	return &AdaptiveRadixTree{
 		mu:   new(sync.RWMutex),
-		tree: goapt.New(),
+		tree: goart.New(),
 	}
 }
 
-func (art *AdaptiveRadixTree) Put(key []byte, value IndexValueType) IndexValueType {
-	panic("unimplement adaptive radix tree index")
+func (art *AdaptiveRadixTree) Put(key []byte, value IndexValueType) (IndexValueType, error) {
+	art.mu.Lock()
+	defer art.mu.Unlock()
+
+	oldValue, updated := art.tree.Insert(key, value)
+	if !updated || oldValue == nil {
+		return nil, nil
+	}
+
+	return oldValue.(IndexValueType), nil
 }
 
-func (art *AdaptiveRadixTree) Get(key []byte) IndexValueType {
-	panic("unimplement adaptive radix tree index")
+func (art *AdaptiveRadixTree) Get(key []byte) (IndexValueType, error) {
+	art.mu.RLock()
+	defer art.mu.RUnlock()
 
+	value, found := art.tree.Search(key)
+	if !found || value == nil {
+		return nil, nil
+	}
+
+	return value.(IndexValueType), nil
 }
 
-func (art *AdaptiveRadixTree) Delete(key []byte) IndexValueType {
-	panic("unimplement adaptive radix tree index")
+func (art *AdaptiveRadixTree) Delete(key []byte) (IndexValueType, error) {
+	art.mu.Lock()
+	defer art.mu.Unlock()
+
+	oldValue, deleted := art.tree.Delete(key)
+	if !deleted || oldValue == nil {
+		return nil, nil
+	}
 
+	return oldValue.(IndexValueType), nil
 }
 
 func (art *AdaptiveRadixTree) Iterator(reverse bool) Iterator {
-	panic("unimplement adaptive radix tree index")
+	art.mu.RLock()
+	defer art.mu.RUnlock()
 
+	return newARTIterator(art.tree, reverse)
 }
 
-func (art *AdaptiveRadixTree) Size() int {
-	panic("unimplement adaptive radix tree index")
+func (art *AdaptiveRadixTree) Size() (int, error) {
+	art.mu.RLock()
+	defer art.mu.RUnlock()
+
+	return art.tree.Size(), nil
+}
+
+func (art *AdaptiveRadixTree) Close() error {
+	return nil
+}
+
+// ART iter
+type artIterator struct {
+	currIndex int
+	reverse   bool
+	keys      [][]byte
+	values    []*data.LogRecordPos
+}
+
+func newARTIterator(tree goart.Tree, reverse bool) *artIterator {
+	var idx int
+	size := tree.Size()
+	keys := make([][]byte, size)
+	values := make([]*data.LogRecordPos, size)
+
+	// leaves are visited in key order
+	saveValues := func(node goart.Node) bool {
+		slot := idx
+		if reverse {
+			slot = size - 1 - idx
+		}
+		keys[slot] = node.Key()
+		values[slot] = node.Value().(*data.LogRecordPos)
+		idx++
+		return true
+	}
+
+	tree.ForEach(saveValues)
+
+	return &artIterator{currIndex: 0, reverse: reverse, keys: keys, values: values}
+}
+
+func (iter *artIterator) Rewind() {
+	iter.currIndex = 0
+}
+
+func (iter *artIterator) Seek(key []byte) {
+	if iter.reverse {
+		iter.currIndex = sort.Search(len(iter.keys), func(i int) bool {
+			return bytes.Compare(iter.keys[i], key) <= 0
+		})
+	} else {
+		iter.currIndex = sort.Search(len(iter.keys), func(i int) bool {
+			return bytes.Compare(iter.keys[i], key) >= 0
+		})
+	}
+}
+
+func (iter *artIterator) Next() {
+	iter.currIndex += 1
+}
+
+func (iter *artIterator) Valid() bool {
+	return iter.currIndex < len(iter.keys)
+}
+
+func (iter *artIterator) Key() []byte {
+	return iter.keys[iter.currIndex]
+}
+
+func (iter *artIterator) Value() *data.LogRecordPos {
+	return iter.values[iter.currIndex]
+}
 
+func (iter *artIterator) Close() {
+	iter.keys = nil
+	iter.values = nil
 }
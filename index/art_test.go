@@ -0,0 +1,77 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestART_Put_Get_Delete(t *testing.T) {
+	art := NewARTIndex()
+
+	res, _ := art.Put([]byte("key-1"), &data.LogRecordPos{FileId: 1, Offset: 12})
+	assert.Nil(t, res)
+	res, _ = art.Put([]byte("key-2"), &data.LogRecordPos{FileId: 1, Offset: 24})
+	assert.Nil(t, res)
+
+	res, _ = art.Put([]byte("key-1"), &data.LogRecordPos{FileId: 2, Offset: 36})
+	assert.Equal(t, int64(12), res.Offset)
+	size, _ := art.Size()
+	assert.Equal(t, 2, size)
+
+	pos, _ := art.Get([]byte("key-1"))
+	assert.Equal(t, uint32(2), pos.FileId)
+	assert.Equal(t, int64(36), pos.Offset)
+	pos, _ = art.Get([]byte("key-3"))
+	assert.Nil(t, pos)
+
+	res, _ = art.Delete([]byte("key-1"))
+	assert.Equal(t, int64(36), res.Offset)
+	res, _ = art.Delete([]byte("key-1"))
+	assert.Nil(t, res)
+	pos, _ = art.Get([]byte("key-1"))
+	assert.Nil(t, pos)
+	size, _ = art.Size()
+	assert.Equal(t, 1, size)
+}
+
+func TestART_Iterator(t *testing.T) {
+	art := NewARTIndex()
+
+	keys := []string{"ab", "a", "abc", "b", "ba", "c"}
+	for i, key := range keys {
+		art.Put([]byte(key), &data.LogRecordPos{FileId: 1, Offset: int64(i)})
+	}
+
+	// ordered
+	iter := art.Iterator(false)
+	var got []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"a", "ab", "abc", "b", "ba", "c"}, got)
+
+	iter.Seek([]byte("abd"))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, []byte("b"), iter.Key())
+	assert.Equal(t, int64(3), iter.Value().Offset)
+	iter.Close()
+
+	// reverse
+	iter = art.Iterator(true)
+	got = nil
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"c", "ba", "b", "abc", "ab", "a"}, got)
+
+	iter.Seek([]byte("abd"))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, []byte("abc"), iter.Key())
+	iter.Close()
+
+	// empty tree
+	iter = NewARTIndex().Iterator(false)
+	assert.False(t, iter.Valid())
+}
@@ -0,0 +1,316 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/fio"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const BPlusTreeFileName = "bptree-index"
+
+var ErrReadOnlySnapshot = errors.New("snapshot of index is read only")
+
+var (
+	indexBucketName      = []byte("bitcask-index")
+	checkpointBucketName = []byte("bitcask-checkpoint")
+	checkpointKey        = []byte("checkpoint")
+)
+
+// BPlusTree is a disk resident keydir based on bbolt
+// you can see more at https://github.com/etcd-io/bbolt
+//
+// keydir is persisted in a single file in db dir, so the number of keys is not
+// limited by memory, and a checkpoint saved with keydir tells db where to
+// replay log records from at startup
+type BPlusTree struct {
+	tree *bbolt.DB
+}
+
+// open or create bptree index file in dirPath
+// updates are not fsynced until checkpoint is saved, keydir is rebuilt
+// from data files if db crashes before it
+func NewBPlusTree(dirPath string) (*BPlusTree, error) {
+	opts := bbolt.DefaultOptions
+	opts.NoSync = true
+	opts.Timeout = time.Second
+	// bbolt remaps file when it grows, which waits for all read transactions,
+	// a large initial mmap size delays it until index file exceeds 1GB
+	opts.InitialMmapSize = 1 << 30
+
+	tree, err := bbolt.Open(filepath.Join(dirPath, BPlusTreeFileName), fio.FileDataPerm, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// create buckets
+	err = tree.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(indexBucketName); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(checkpointBucketName)
+		return err
+	})
+	if err != nil {
+		_ = tree.Close()
+		return nil, err
+	}
+
+	return &BPlusTree{tree: tree}, nil
+}
+
+func (bpt *BPlusTree) Put(key []byte, value IndexValueType) (IndexValueType, error) {
+	var oldValue IndexValueType
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		if oldBuf := bucket.Get(key); len(oldBuf) != 0 {
+			oldValue = data.DecodeLogRecordPos(oldBuf)
+		}
+		return bucket.Put(key, data.EncodeLogRecordPos(value))
+	}); err != nil {
+		return nil, err
+	}
+
+	return oldValue, nil
+}
+
+func (bpt *BPlusTree) Get(key []byte) (IndexValueType, error) {
+	var value IndexValueType
+	if err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		if buf := bucket.Get(key); len(buf) != 0 {
+			value = data.DecodeLogRecordPos(buf)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (bpt *BPlusTree) Delete(key []byte) (IndexValueType, error) {
+	var oldValue IndexValueType
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		if oldBuf := bucket.Get(key); len(oldBuf) != 0 {
+			oldValue = data.DecodeLogRecordPos(oldBuf)
+			return bucket.Delete(key)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return oldValue, nil
+}
+
+// iterator holds a read transaction of bbolt until it is closed,
+// writing in the same goroutine hangs if index file has to be remapped
+func (bpt *BPlusTree) Iterator(reverse bool) Iterator {
+	return newBptreeIterator(bpt.tree, reverse)
+}
+
+func (bpt *BPlusTree) Size() (int, error) {
+	var size int
+	if err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		size = tx.Bucket(indexBucketName).Stats().KeyN
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func (bpt *BPlusTree) Close() error {
+	return bpt.tree.Close()
+}
+
+// sync index file to disk, then save checkpoint and sync it,
+// so checkpoint never claims updates which haven't been persisted
+func (bpt *BPlusTree) SaveCheckpoint(checkpoint []byte) error {
+	if err := bpt.tree.Sync(); err != nil {
+		return err
+	}
+
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(checkpointBucketName).Put(checkpointKey, checkpoint)
+	}); err != nil {
+		return err
+	}
+
+	return bpt.tree.Sync()
+}
+
+// return checkpoint saved last time, nil if not exist
+func (bpt *BPlusTree) LoadCheckpoint() ([]byte, error) {
+	var checkpoint []byte
+	err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		if buf := tx.Bucket(checkpointBucketName).Get(checkpointKey); buf != nil {
+			checkpoint = make([]byte, len(buf))
+			copy(checkpoint, buf)
+		}
+		return nil
+	})
+
+	return checkpoint, err
+}
+
+// remove checkpoint, db will rebuild index at next startup
+func (bpt *BPlusTree) RemoveCheckpoint() error {
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(checkpointBucketName).Delete(checkpointKey)
+	}); err != nil {
+		return err
+	}
+
+	return bpt.tree.Sync()
+}
+
+// snapshot is a read transaction of bbolt, writers don't wait for it
+// unless index file has to be remapped
+func (bpt *BPlusTree) Snapshot() (Indexer, error) {
+	tx, err := bpt.tree.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bptreeSnapshot{mu: new(sync.Mutex), tx: tx}, nil
+}
+
+// read-only view of bptree, it's used by multiple goroutines, so accesses
+// to transaction are serialized
+type bptreeSnapshot struct {
+	mu *sync.Mutex
+	tx *bbolt.Tx
+}
+
+func (snap *bptreeSnapshot) Put(key []byte, value IndexValueType) (IndexValueType, error) {
+	return nil, ErrReadOnlySnapshot
+}
+
+func (snap *bptreeSnapshot) Get(key []byte) (IndexValueType, error) {
+	snap.mu.Lock()
+	defer snap.mu.Unlock()
+
+	if buf := snap.tx.Bucket(indexBucketName).Get(key); len(buf) != 0 {
+		return data.DecodeLogRecordPos(buf), nil
+	}
+	return nil, nil
+}
+
+func (snap *bptreeSnapshot) Delete(key []byte) (IndexValueType, error) {
+	return nil, ErrReadOnlySnapshot
+}
+
+// iterator shares transaction of snapshot, it's valid until snapshot is closed
+func (snap *bptreeSnapshot) Iterator(reverse bool) Iterator {
+	snap.mu.Lock()
+	defer snap.mu.Unlock()
+
+	iter := &bptreeIterator{
+		cursor:  snap.tx.Bucket(indexBucketName).Cursor(),
+		reverse: reverse,
+	}
+	iter.Rewind()
+
+	return iter
+}
+
+func (snap *bptreeSnapshot) Size() (int, error) {
+	snap.mu.Lock()
+	defer snap.mu.Unlock()
+
+	return snap.tx.Bucket(indexBucketName).Stats().KeyN, nil
+}
+
+func (snap *bptreeSnapshot) Close() error {
+	snap.mu.Lock()
+	defer snap.mu.Unlock()
+
+	if err := snap.tx.Rollback(); err != nil && err != bbolt.ErrTxClosed {
+		return err
+	}
+	return nil
+}
+
+// BPlusTree iter
+type bptreeIterator struct {
+	tx        *bbolt.Tx // owned by iterator, nil if it's shared with snapshot
+	cursor    *bbolt.Cursor
+	reverse   bool
+	currKey   []byte
+	currValue []byte
+}
+
+func newBptreeIterator(tree *bbolt.DB, reverse bool) *bptreeIterator {
+	tx, err := tree.Begin(false)
+	if err != nil {
+		panic("failed to begin a transaction of bptree")
+	}
+
+	iter := &bptreeIterator{
+		tx:      tx,
+		cursor:  tx.Bucket(indexBucketName).Cursor(),
+		reverse: reverse,
+	}
+	iter.Rewind()
+
+	return iter
+}
+
+func (iter *bptreeIterator) Rewind() {
+	if iter.reverse {
+		iter.currKey, iter.currValue = iter.cursor.Last()
+	} else {
+		iter.currKey, iter.currValue = iter.cursor.First()
+	}
+}
+
+func (iter *bptreeIterator) Seek(key []byte) {
+	iter.currKey, iter.currValue = iter.cursor.Seek(key)
+	if !iter.reverse {
+		return
+	}
+
+	// find the last item which less equal than key
+	if iter.currKey == nil {
+		iter.currKey, iter.currValue = iter.cursor.Last()
+	} else if string(iter.currKey) != string(key) {
+		iter.currKey, iter.currValue = iter.cursor.Prev()
+	}
+}
+
+func (iter *bptreeIterator) Next() {
+	if iter.reverse {
+		iter.currKey, iter.currValue = iter.cursor.Prev()
+	} else {
+		iter.currKey, iter.currValue = iter.cursor.Next()
+	}
+}
+
+func (iter *bptreeIterator) Valid() bool {
+	return len(iter.currKey) != 0
+}
+
+// key is copied, memory of bbolt is invalid after transaction closed
+func (iter *bptreeIterator) Key() []byte {
+	key := make([]byte, len(iter.currKey))
+	copy(key, iter.currKey)
+	return key
+}
+
+func (iter *bptreeIterator) Value() *data.LogRecordPos {
+	return data.DecodeLogRecordPos(iter.currValue)
+}
+
+func (iter *bptreeIterator) Close() {
+	if iter.tx != nil {
+		_ = iter.tx.Rollback()
+	}
+}
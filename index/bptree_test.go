@@ -0,0 +1,167 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBPlusTree_Put_Get_Delete(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree")
+	defer os.RemoveAll(dir)
+
+	bpt, err := NewBPlusTree(dir)
+	assert.Nil(t, err)
+
+	res, err := bpt.Put([]byte("key-1"), &data.LogRecordPos{FileId: 1, Offset: 12, Size: 300})
+	assert.Nil(t, err)
+	assert.Nil(t, res)
+	res, err = bpt.Put([]byte("key-1"), &data.LogRecordPos{FileId: 2, Offset: 24, Size: 400})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), res.FileId)
+	assert.Equal(t, int64(12), res.Offset)
+	assert.Equal(t, uint32(300), res.Size)
+
+	pos, err := bpt.Get([]byte("key-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), pos.FileId)
+	assert.Equal(t, int64(24), pos.Offset)
+	assert.Equal(t, uint32(400), pos.Size)
+	pos, err = bpt.Get([]byte("key-2"))
+	assert.Nil(t, err)
+	assert.Nil(t, pos)
+	size, err := bpt.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, size)
+
+	// reopen
+	err = bpt.Close()
+	assert.Nil(t, err)
+	bpt, err = NewBPlusTree(dir)
+	assert.Nil(t, err)
+
+	pos, err = bpt.Get([]byte("key-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(24), pos.Offset)
+
+	res, err = bpt.Delete([]byte("key-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(24), res.Offset)
+	res, err = bpt.Delete([]byte("key-1"))
+	assert.Nil(t, err)
+	assert.Nil(t, res)
+	size, err = bpt.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, size)
+
+	err = bpt.Close()
+	assert.Nil(t, err)
+}
+
+func TestBPlusTree_Iterator(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-iter")
+	defer os.RemoveAll(dir)
+
+	bpt, err := NewBPlusTree(dir)
+	assert.Nil(t, err)
+	defer bpt.Close()
+
+	keys := []string{"ddd", "aaa", "ccc", "bbb"}
+	for i, key := range keys {
+		bpt.Put([]byte(key), &data.LogRecordPos{FileId: 1, Offset: int64(i)})
+	}
+
+	iter := bpt.Iterator(false)
+	var got []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"aaa", "bbb", "ccc", "ddd"}, got)
+
+	iter.Seek([]byte("bbc"))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, []byte("ccc"), iter.Key())
+	assert.Equal(t, int64(2), iter.Value().Offset)
+	iter.Close()
+
+	iter = bpt.Iterator(true)
+	got = nil
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"ddd", "ccc", "bbb", "aaa"}, got)
+
+	iter.Seek([]byte("bbc"))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, []byte("bbb"), iter.Key())
+	iter.Seek([]byte("zzz"))
+	assert.Equal(t, []byte("ddd"), iter.Key())
+	iter.Close()
+}
+
+func TestBPlusTree_Checkpoint(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-checkpoint")
+	defer os.RemoveAll(dir)
+
+	bpt, err := NewBPlusTree(dir)
+	assert.Nil(t, err)
+	defer bpt.Close()
+
+	cp, err := bpt.LoadCheckpoint()
+	assert.Nil(t, err)
+	assert.Nil(t, cp)
+
+	err = bpt.SaveCheckpoint([]byte("checkpoint"))
+	assert.Nil(t, err)
+	cp, err = bpt.LoadCheckpoint()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("checkpoint"), cp)
+
+	err = bpt.RemoveCheckpoint()
+	assert.Nil(t, err)
+	cp, err = bpt.LoadCheckpoint()
+	assert.Nil(t, err)
+	assert.Nil(t, cp)
+}
+
+func TestBPlusTree_Snapshot(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-snapshot")
+	defer os.RemoveAll(dir)
+
+	bpt, err := NewBPlusTree(dir)
+	assert.Nil(t, err)
+	_, err = bpt.Put([]byte("a"), &data.LogRecordPos{FileId: 1, Offset: 2})
+	assert.Nil(t, err)
+	_, err = bpt.Put([]byte("b"), &data.LogRecordPos{FileId: 1, Offset: 10})
+	assert.Nil(t, err)
+
+	snap, err := bpt.Snapshot()
+	assert.Nil(t, err)
+
+	// updates after snapshot are invisible to it
+	_, err = bpt.Put([]byte("a"), &data.LogRecordPos{FileId: 2, Offset: 2})
+	assert.Nil(t, err)
+	_, err = bpt.Delete([]byte("b"))
+	assert.Nil(t, err)
+
+	pos, err := snap.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), pos.FileId)
+	pos, err = snap.Get([]byte("b"))
+	assert.Nil(t, err)
+	assert.NotNil(t, pos)
+
+	iter := snap.Iterator(true)
+	assert.Equal(t, []byte("b"), iter.Key())
+	iter.Next()
+	assert.Equal(t, []byte("a"), iter.Key())
+	iter.Close()
+
+	_, err = snap.Put([]byte("c"), &data.LogRecordPos{FileId: 2, Offset: 30})
+	assert.Equal(t, ErrReadOnlySnapshot, err)
+
+	assert.Nil(t, snap.Close())
+	assert.Nil(t, bpt.Close())
+}
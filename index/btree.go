@@ -38,41 +38,41 @@ func newBTree(degree int) *BTree {
 	}
 }
 
-func (bt *BTree) Put(key []byte, pos *data.LogRecordPos) IndexValueType {
+func (bt *BTree) Put(key []byte, pos *data.LogRecordPos) (IndexValueType, error) {
 	it := &BTreeItem{key: key, val: pos}
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 	oldItem := bt.tree.ReplaceOrInsert(it)
 
 	if oldItem == nil {
-		return nil
+		return nil, nil
 	}
-	return oldItem.(*BTreeItem).val
+	return oldItem.(*BTreeItem).val, nil
 }
 
-func (bt *BTree) Get(key []byte) IndexValueType {
+func (bt *BTree) Get(key []byte) (IndexValueType, error) {
 	it := &BTreeItem{key: key}
 	bt.mu.RLock()
 	defer bt.mu.RUnlock()
 
 	getResult := bt.tree.Get(it)
 	if getResult == nil {
-		return nil
+		return nil, nil
 	}
-	return getResult.(*BTreeItem).val
+	return getResult.(*BTreeItem).val, nil
 }
 
-func (bt *BTree) Delete(key []byte) IndexValueType {
+func (bt *BTree) Delete(key []byte) (IndexValueType, error) {
 	it := &BTreeItem{key: key}
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
 	deleteItem := bt.tree.Delete(it)
 	if deleteItem == nil {
-		return nil
+		return nil, nil
 	}
 
-	return deleteItem.(*BTreeItem).val
+	return deleteItem.(*BTreeItem).val, nil
 }
 
 func (bt *BTree) Iterator(reverse bool) Iterator {
@@ -86,8 +86,23 @@ func (bt *BTree) Iterator(reverse bool) Iterator {
 	return newBtreeIter(bt.tree, reverse)
 }
 
-func (bt *BTree) Size() int {
-	return bt.tree.Len()
+func (bt *BTree) Size() (int, error) {
+	return bt.tree.Len(), nil
+}
+
+func (bt *BTree) Close() error {
+	return nil
+}
+
+// clone is lazy, nodes are copied on write by either tree, so it costs O(1)
+func (bt *BTree) Snapshot() (Indexer, error) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	return &BTree{
+		tree: bt.tree.Clone(),
+		mu:   new(sync.RWMutex),
+	}, nil
 }
 
 // BTree iter
@@ -11,34 +11,34 @@ import (
 
 func TestBTree_Put(t *testing.T) {
 	bt := newBTree(-1)
-	res := bt.Put(nil, &data.LogRecordPos{FileId: 1, Offset: 300})
+	res, _ := bt.Put(nil, &data.LogRecordPos{FileId: 1, Offset: 300})
 	assert.Nil(t, res)
 
-	res2 := bt.Put([]byte("a"), &data.LogRecordPos{FileId: 1, Offset: 2})
+	res2, _ := bt.Put([]byte("a"), &data.LogRecordPos{FileId: 1, Offset: 2})
 	assert.Nil(t, res2)
 }
 
 func TestBTree_Get(t *testing.T) {
 	bt := newBTree(-1)
 
-	res1 := bt.Put(nil, &data.LogRecordPos{FileId: 1, Offset: 300})
+	res1, _ := bt.Put(nil, &data.LogRecordPos{FileId: 1, Offset: 300})
 	assert.Nil(t, res1)
 
-	pos1 := bt.Get(nil)
+	pos1, _ := bt.Get(nil)
 	assert.Equal(t, uint32(1), pos1.FileId)
 	assert.Equal(t, int64(300), pos1.Offset)
 
-	res2 := bt.Put([]byte("a"), &data.LogRecordPos{FileId: 1, Offset: 2})
+	res2, _ := bt.Put([]byte("a"), &data.LogRecordPos{FileId: 1, Offset: 2})
 	assert.Nil(t, res2)
 
-	pos2 := bt.Get([]byte("a"))
+	pos2, _ := bt.Get([]byte("a"))
 	assert.Equal(t, uint32(1), pos2.FileId)
 	assert.Equal(t, int64(2), pos2.Offset)
 
-	res3 := bt.Put([]byte("b"), &data.LogRecordPos{FileId: 1, Offset: 10})
+	res3, _ := bt.Put([]byte("b"), &data.LogRecordPos{FileId: 1, Offset: 10})
 	assert.Nil(t, res3)
 
-	pos3 := bt.Get([]byte("b"))
+	pos3, _ := bt.Get([]byte("b"))
 	assert.Equal(t, uint32(1), pos3.FileId)
 	assert.Equal(t, int64(10), pos3.Offset)
 }
@@ -50,22 +50,28 @@ func TestBTree_Delete(t *testing.T) {
 	bt.Put([]byte("a"), &data.LogRecordPos{FileId: 1, Offset: 2})
 	bt.Put([]byte("b"), &data.LogRecordPos{FileId: 1, Offset: 10})
 
-	pos1 := bt.Get(nil)
+	pos1, _ := bt.Get(nil)
 	assert.True(t, pos1 != nil)
 
-	pos2 := bt.Get([]byte("a"))
+	pos2, _ := bt.Get([]byte("a"))
 	assert.True(t, pos2 != nil)
 
-	pos3 := bt.Get([]byte("b"))
+	pos3, _ := bt.Get([]byte("b"))
 	assert.True(t, pos3 != nil)
 
-	assert.NotNil(t, bt.Delete(nil))
-	assert.NotNil(t, bt.Delete(([]byte("a"))))
-	assert.NotNil(t, bt.Delete(([]byte("b"))))
-
-	assert.True(t, bt.Get(nil) == nil)
-	assert.True(t, bt.Get([]byte("a")) == nil)
-	assert.True(t, bt.Get([]byte("b")) == nil)
+	res, _ := bt.Delete(nil)
+	assert.NotNil(t, res)
+	res, _ = bt.Delete(([]byte("a")))
+	assert.NotNil(t, res)
+	res, _ = bt.Delete(([]byte("b")))
+	assert.NotNil(t, res)
+
+	pos, _ := bt.Get(nil)
+	assert.True(t, pos == nil)
+	pos, _ = bt.Get([]byte("a"))
+	assert.True(t, pos == nil)
+	pos, _ = bt.Get([]byte("b"))
+	assert.True(t, pos == nil)
 }
 
 func TestBtree_Iter(t *testing.T) {
@@ -99,3 +105,30 @@ func TestBtree_Iter(t *testing.T) {
 
 	iter.Close()
 }
+
+func TestBTree_Snapshot(t *testing.T) {
+	bt := newBTree(-1)
+	bt.Put([]byte("a"), &data.LogRecordPos{FileId: 1, Offset: 2})
+	bt.Put([]byte("b"), &data.LogRecordPos{FileId: 1, Offset: 10})
+
+	snap, err := bt.Snapshot()
+	assert.Nil(t, err)
+
+	// updates after snapshot are invisible to it
+	bt.Put([]byte("a"), &data.LogRecordPos{FileId: 2, Offset: 2})
+	bt.Delete([]byte("b"))
+	bt.Put([]byte("c"), &data.LogRecordPos{FileId: 2, Offset: 30})
+
+	pos, _ := snap.Get([]byte("a"))
+	assert.Equal(t, uint32(1), pos.FileId)
+	pos, _ = snap.Get([]byte("b"))
+	assert.NotNil(t, pos)
+	pos, _ = snap.Get([]byte("c"))
+	assert.Nil(t, pos)
+	size, _ := snap.Size()
+	assert.Equal(t, 2, size)
+
+	pos, _ = bt.Get([]byte("a"))
+	assert.Equal(t, uint32(2), pos.FileId)
+	assert.Nil(t, snap.Close())
+}
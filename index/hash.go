@@ -1,49 +1,140 @@
 package index
 
-import "sync"
+import (
+	"bitcask-go/data"
+	"bytes"
+	"sort"
+	"sync"
+)
 
+// HashIndex is a keydir based on go map
+// it provides O(1) point lookup without tree maintenance cost,
+// iterator sorts keys on demand, so it costs O(nlogn) to create a iterator
 type HashIndex struct {
 	mu   *sync.RWMutex
 	hash map[string]IndexValueType
 }
 
-func (h *HashIndex) Put(key []byte, value IndexValueType) IndexValueType {
+func NewHashIndex() *HashIndex {
+	return &HashIndex{
+		mu:   new(sync.RWMutex),
+		hash: make(map[string]IndexValueType),
+	}
+}
+
+func (h *HashIndex) Put(key []byte, value IndexValueType) (IndexValueType, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	oldval := h.hash[string(key)]
 	h.hash[string(key)] = value
 
-	return oldval
+	return oldval, nil
 }
 
 // get value with key, value is rid of data, return nil if key doesn't exist
-func (h *HashIndex) Get(key []byte) IndexValueType {
+func (h *HashIndex) Get(key []byte) (IndexValueType, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	return h.hash[string(key)]
+	return h.hash[string(key)], nil
 }
 
 // delete item with key, return old value if delete successm, nil if key doesn't exist
-func (h *HashIndex) Delete(key []byte) IndexValueType {
+func (h *HashIndex) Delete(key []byte) (IndexValueType, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if oldval, ok := h.hash[string(key)]; ok {
 		delete(h.hash, string(key))
-		return oldval
+		return oldval, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
-// create a iterator for index
+// create a iterator for index, items are sorted when iterator is created
 func (h *HashIndex) Iterator(reverse bool) Iterator {
-	panic("Unimplement")
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return newHashIterator(h.hash, reverse)
 }
 
 // return item count of index
-func (h *HashIndex) Size() int {
-	return len(h.hash)
+func (h *HashIndex) Size() (int, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.hash), nil
+}
+
+func (h *HashIndex) Close() error {
+	return nil
+}
+
+// Hash iter
+type hashIterator struct {
+	currIndex int
+	reverse   bool
+	keys      [][]byte
+	values    []*data.LogRecordPos
+}
+
+func newHashIterator(hash map[string]IndexValueType, reverse bool) *hashIterator {
+	keys := make([][]byte, 0, len(hash))
+	for key := range hash {
+		keys = append(keys, []byte(key))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if reverse {
+			return bytes.Compare(keys[i], keys[j]) > 0
+		}
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	values := make([]*data.LogRecordPos, len(keys))
+	for i, key := range keys {
+		values[i] = hash[string(key)]
+	}
+
+	return &hashIterator{currIndex: 0, reverse: reverse, keys: keys, values: values}
+}
+
+func (iter *hashIterator) Rewind() {
+	iter.currIndex = 0
+}
+
+func (iter *hashIterator) Seek(key []byte) {
+	if iter.reverse {
+		iter.currIndex = sort.Search(len(iter.keys), func(i int) bool {
+			return bytes.Compare(iter.keys[i], key) <= 0
+		})
+	} else {
+		iter.currIndex = sort.Search(len(iter.keys), func(i int) bool {
+			return bytes.Compare(iter.keys[i], key) >= 0
+		})
+	}
+}
+
+func (iter *hashIterator) Next() {
+	iter.currIndex += 1
+}
+
+func (iter *hashIterator) Valid() bool {
+	return iter.currIndex < len(iter.keys)
+}
+
+func (iter *hashIterator) Key() []byte {
+	return iter.keys[iter.currIndex]
+}
+
+func (iter *hashIterator) Value() *data.LogRecordPos {
+	return iter.values[iter.currIndex]
+}
+
+func (iter *hashIterator) Close() {
+	iter.keys = nil
+	iter.values = nil
 }
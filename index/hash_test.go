@@ -0,0 +1,63 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashIndex_Put_Get_Delete(t *testing.T) {
+	h := NewHashIndex()
+
+	res, _ := h.Put([]byte("key-1"), &data.LogRecordPos{FileId: 1, Offset: 12})
+	assert.Nil(t, res)
+	res, _ = h.Put([]byte("key-1"), &data.LogRecordPos{FileId: 1, Offset: 24})
+	assert.Equal(t, int64(12), res.Offset)
+
+	pos, _ := h.Get([]byte("key-1"))
+	assert.Equal(t, int64(24), pos.Offset)
+	pos, _ = h.Get([]byte("key-2"))
+	assert.Nil(t, pos)
+
+	res, _ = h.Delete([]byte("key-1"))
+	assert.Equal(t, int64(24), res.Offset)
+	res, _ = h.Delete([]byte("key-1"))
+	assert.Nil(t, res)
+	size, _ := h.Size()
+	assert.Equal(t, 0, size)
+}
+
+func TestHashIndex_Iterator(t *testing.T) {
+	h := NewHashIndex()
+
+	keys := []string{"ddd", "aaa", "ccc", "bbb"}
+	for i, key := range keys {
+		h.Put([]byte(key), &data.LogRecordPos{FileId: 1, Offset: int64(i)})
+	}
+
+	iter := h.Iterator(false)
+	var got []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"aaa", "bbb", "ccc", "ddd"}, got)
+
+	iter.Seek([]byte("bbc"))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, []byte("ccc"), iter.Key())
+	assert.Equal(t, int64(2), iter.Value().Offset)
+	iter.Close()
+
+	iter = h.Iterator(true)
+	got = nil
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"ddd", "ccc", "bbb", "aaa"}, got)
+
+	iter.Seek([]byte("bbc"))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, []byte("bbb"), iter.Key())
+	iter.Close()
+}
@@ -13,24 +13,51 @@ const (
 	RBTREE
 	ARTREE
 	BPLUSTREE
+	HASH
 )
 
-// in-memory key dir interface
+// key dir interface, errors are only returned by keydir persisted on disk
 type Indexer interface {
 	// put <key, value> to btree, return nil if key doesn't, else old value
-	Put(key []byte, value IndexValueType) IndexValueType
+	Put(key []byte, value IndexValueType) (IndexValueType, error)
 
 	// get value with key, value is rid of data, return nil if key doesn't exist
-	Get(key []byte) IndexValueType
+	Get(key []byte) (IndexValueType, error)
 
 	// delete item with key, return old value if delete successm, nil if key doesn't exist
-	Delete(key []byte) IndexValueType
+	Delete(key []byte) (IndexValueType, error)
 
 	// create a iterator for index
 	Iterator(reverse bool) Iterator
 
 	// return item count of index
-	Size() int
+	Size() (int, error)
+
+	// close index, release resources held by it
+	Close() error
+}
+
+// keydir persisted on disk, it saves a checkpoint which tells
+// db where to replay log records from at startup
+type PersistentIndexer interface {
+	Indexer
+
+	// save checkpoint and persist index
+	SaveCheckpoint(checkpoint []byte) error
+
+	// return checkpoint saved last time, nil if not exist
+	LoadCheckpoint() ([]byte, error)
+
+	// remove checkpoint, index must be rebuilt at next startup
+	RemoveCheckpoint() error
+}
+
+// keydir which takes a point-in-time view of itself without copying all keys
+type SnapshotIndexer interface {
+	Indexer
+
+	// return a read-only view of index at this moment, it must be closed after using
+	Snapshot() (Indexer, error)
 }
 
 type Iterator interface {
@@ -56,12 +83,19 @@ type Iterator interface {
 	Close()
 }
 
-func NewIndexer(typ IndexType) Indexer {
+// create keydir, dirPath and syncWrites are only used by persistent keydir
+func NewIndexer(typ IndexType, dirPath string) (Indexer, error) {
 	switch typ {
 	case BTREE:
-		return newBTree(-1)
+		return newBTree(-1), nil
 	case RBTREE:
-		return NewRBTree()
+		return NewRBTree(), nil
+	case ARTREE:
+		return NewARTIndex(), nil
+	case BPLUSTREE:
+		return NewBPlusTree(dirPath)
+	case HASH:
+		return NewHashIndex(), nil
 	default:
 		panic("unsopported index type")
 	}
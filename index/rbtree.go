@@ -31,7 +31,7 @@ func NewRBTree() *RBTree {
 	}
 }
 
-func (rbt *RBTree) Put(key []byte, value IndexValueType) IndexValueType {
+func (rbt *RBTree) Put(key []byte, value IndexValueType) (IndexValueType, error) {
 	it := &RBTreeItem{key: key, val: value}
 
 	rbt.mu.Lock()
@@ -39,16 +39,16 @@ func (rbt *RBTree) Put(key []byte, value IndexValueType) IndexValueType {
 	oldItem := rbt.tree.InsertOrGet(it).(*RBTreeItem)
 
 	if oldItem.val == value {
-		return nil
+		return nil, nil
 	}
 
 	oldval := oldItem.val
 	oldItem.val = value
 
-	return oldval
+	return oldval, nil
 }
 
-func (rbt *RBTree) Get(key []byte) IndexValueType {
+func (rbt *RBTree) Get(key []byte) (IndexValueType, error) {
 	it := &RBTreeItem{key: key}
 
 	rbt.mu.RLock()
@@ -56,21 +56,21 @@ func (rbt *RBTree) Get(key []byte) IndexValueType {
 
 	item := rbt.tree.Get(it)
 	if item == nil {
-		return nil
+		return nil, nil
 	}
-	return item.(*RBTreeItem).val
+	return item.(*RBTreeItem).val, nil
 }
 
-func (rbt *RBTree) Delete(key []byte) IndexValueType {
+func (rbt *RBTree) Delete(key []byte) (IndexValueType, error) {
 	it := &RBTreeItem{key: key}
 	rbt.mu.Lock()
 	defer rbt.mu.Unlock()
 
 	item := rbt.tree.Delete(it)
 	if item == nil {
-		return nil
+		return nil, nil
 	}
-	return item.(*RBTreeItem).val
+	return item.(*RBTreeItem).val, nil
 }
 
 func (rbt *RBTree) Iterator(reverse bool) Iterator {
@@ -83,10 +83,14 @@ func (rbt *RBTree) Iterator(reverse bool) Iterator {
 	return newRBtreeIter(rbt.tree, reverse)
 }
 
-func (rbt *RBTree) Size() int {
+func (rbt *RBTree) Size() (int, error) {
 	rbt.mu.RLock()
 	defer rbt.mu.RUnlock()
-	return int(rbt.tree.Len())
+	return int(rbt.tree.Len()), nil
+}
+
+func (rbt *RBTree) Close() error {
+	return nil
 }
 
 // BTree iter
@@ -8,22 +8,22 @@ import (
 
 func TestTBTree_Put(t *testing.T) {
 	rbt := NewRBTree()
-	ans := rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 11})
+	ans, _ := rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 11})
 	log.Printf("key1: %v\n", ans)
 
-	ans = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 21})
+	ans, _ = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 21})
 	log.Printf("key1: %v\n", ans)
 
-	ans = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 31})
+	ans, _ = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 31})
 	log.Printf("key1: %v\n", ans)
 
-	ans = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 41})
+	ans, _ = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 41})
 	log.Printf("key1: %v\n", ans)
 
-	ans = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 51})
+	ans, _ = rbt.Put([]byte("key1"), &data.LogRecordPos{FileId: 1, Offset: 51})
 	log.Printf("key1: %v\n", ans)
 
-	pos := rbt.Get([]byte("key1"))
+	pos, _ := rbt.Get([]byte("key1"))
 	log.Printf("key1: %v\n", pos)
 
 }
@@ -8,9 +8,13 @@ import (
 type Iterator struct {
 	indexIter index.Iterator
 	bitcaskDB *DB
+	snapshot  *Snapshot // read values from snapshot if not nil
 	prefix    []byte
 }
 
+// iterator of BPLUSTREE index holds a read transaction of index file until
+// it's closed, writes in the same goroutine may hang once index file exceeds 1GB,
+// so close iterator before writing
 func (db *DB) NewIterator(opt IteratorOptions) *Iterator {
 	indexIter := db.index.Iterator(opt.Reverse)
 	iter := &Iterator{
@@ -45,10 +49,22 @@ func (iter *Iterator) Key() []byte {
 	return iter.indexIter.Key()
 }
 
+// value of live iterator is read from newest position of key,
+// position in index iterator may be replaced by merge
 func (iter *Iterator) Value() ([]byte, error) {
-	pos := iter.indexIter.Value()
 	iter.bitcaskDB.mu.RLock()
 	defer iter.bitcaskDB.mu.RUnlock()
+	if iter.snapshot != nil {
+		return iter.snapshot.getValueByPostion(iter.indexIter.Value())
+	}
+
+	pos, err := iter.bitcaskDB.index.Get(iter.indexIter.Key())
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil {
+		return nil, ErrKeyNotFound
+	}
 	return iter.bitcaskDB.getValueByPostion(pos)
 }
 
@@ -56,13 +72,15 @@ func (iter *Iterator) Close() {
 	iter.indexIter.Close()
 }
 
+// skip expired keys and keys without prefix
 func (iter *Iterator) skipToNext() {
 	prefixLen := len(iter.prefix)
-	if prefixLen == 0 {
-		return
-	}
 
 	for ; iter.indexIter.Valid(); iter.indexIter.Next() {
+		if iter.indexIter.Value().Expired() {
+			continue
+		}
+
 		key := iter.indexIter.Key()
 		if prefixLen <= len(key) && bytes.Equal(iter.prefix, key[:prefixLen]) {
 			break
@@ -2,6 +2,8 @@ package bitcaskgo
 
 import (
 	"bitcask-go/data"
+	"bitcask-go/fio"
+	"bitcask-go/index"
 	"bitcask-go/utils"
 	"io"
 	"os"
@@ -9,12 +11,14 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
 const MergeDir = ".merge"
 const MergeFinKey = "merge.Fin"
+const MergeFilesKey = "merge.Files"
 
 func (db *DB) getAllOlderFiles() []*data.DataFile {
 	var mergeFiles []*data.DataFile
@@ -30,12 +34,6 @@ func (db *DB) checkMergeAvaliable() error {
 	if err != nil {
 		return err
 	}
-	actualRatio := float32(db.reclaimSize) / float32(totalSize)
-	if actualRatio < db.options.MergeRatio {
-		logrus.Infof("Merge failed: actual ratio : %v, expected ratio: %v [reclaimSize:%v, totalSize:%v]\n", actualRatio, db.options.MergeRatio, db.reclaimSize, totalSize)
-		return nil
-	}
-
 	avaliableDiskSize, err := utils.AvaliableDiskSzie()
 	if err != nil {
 		return err
@@ -45,6 +43,12 @@ func (db *DB) checkMergeAvaliable() error {
 		return ErrNoEnoughSpaceForMerge
 	}
 
+	actualRatio := float32(db.reclaimSize) / float32(totalSize)
+	if actualRatio < db.options.MergeRatio {
+		logrus.Infof("Merge ratio unreached: actual ratio : %v, expected ratio: %v [reclaimSize:%v, totalSize:%v]\n", actualRatio, db.options.MergeRatio, db.reclaimSize, totalSize)
+		return ErrMergeRationUnreached
+	}
+
 	return nil
 }
 
@@ -53,7 +57,8 @@ func (db *DB) tryStartMerging() error {
 		return ErrMergeIsPorgress
 	}
 
-	if err := db.checkMergeAvaliable(); err != nil {
+	// manual merge doesn't care about merge ratio
+	if err := db.checkMergeAvaliable(); err != nil && err != ErrMergeRationUnreached {
 		return err
 	}
 	// start merging
@@ -61,39 +66,59 @@ func (db *DB) tryStartMerging() error {
 		return err
 	}
 
-	if err := db.setActiveDataFile(); err != nil {
+	// merged files take ids before new active file, records may become larger
+	// after merging (e.g. encrypted), so leave room for them
+	reservedFiles := uint32(len(db.olderFiles) + 1)
+	if err := db.setActiveDataFileWithId(db.activeFile.FileId + 1 + reservedFiles); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-//  Merge scan the index
+// Merge scan the index
 //
+// Merge rewrites all files before active file, records appended during
+// merge are all in files after them, so a record in merged files is kept
+// if keydir still points at it when it's read, and keydir isn't blocked
+// while merging
 //
+// merged files are installed to db online, keys written during merge
+// keep their newest positions
 func (db *DB) Merge() error {
+	if db.options.ReadOnly {
+		return ErrReadOnly
+	}
+	if db.options.InMemory {
+		return ErrInMemory
+	}
+
+	db.mu.Lock()
+
 	// return if db is empty
 	if db.activeFile == nil {
+		db.mu.Unlock()
 		return nil
 	}
 
-	db.mu.Lock()
-
 	if err := db.tryStartMerging(); err != nil {
 		db.mu.Unlock()
 		return err
 	}
 
 	db.isMerging = true
-	defer func() {
-		db.isMerging = false
-	}()
 
 	nonMergeFid := db.activeFile.FileId
 	mergeFiles := db.getAllOlderFiles()
 
 	db.mu.Unlock()
 
+	defer func() {
+		db.mu.Lock()
+		db.isMerging = false
+		db.mu.Unlock()
+	}()
+
 	// sort merge files by file id
 	sort.Slice(mergeFiles, func(i, j int) bool {
 		return mergeFiles[i].FileId < mergeFiles[j].FileId
@@ -113,48 +138,113 @@ func (db *DB) Merge() error {
 		return err
 	}
 
+	mergedFiles, expiredKeys, err := db.writeMergeFiles(mergePath, mergeFiles)
+	if err != nil {
+		return err
+	}
+	if mergedFiles > nonMergeFid {
+		return ErrMergeFilesOverflow
+	}
+
+	if err := db.writeMergeFinFile(mergePath, nonMergeFid, mergedFiles); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.installMergeFiles(nonMergeFid, mergedFiles, expiredKeys)
+}
+
+// fin file presents merge success, it records first file id which isn't merged
+// and the number of merged data files
+func (db *DB) writeMergeFinFile(mergePath string, nonMergeFid, mergedFiles uint32) error {
+	// write fin file to present merge success
+	mergeFinFile, err := data.OpenMergeFinFile(mergePath, fio.StandFileIO)
+	if err != nil {
+		return err
+	}
+	if err := db.wrapFileIO(mergeFinFile, filepath.Join(mergePath, data.HintFinFileName)); err != nil {
+		return err
+	}
+	defer mergeFinFile.Close()
+
+	finRecords := []*data.LogRecord{
+		{Key: []byte(MergeFinKey), Value: []byte(strconv.Itoa(int(nonMergeFid)))},
+		{Key: []byte(MergeFilesKey), Value: []byte(strconv.Itoa(int(mergedFiles)))},
+	}
+	for _, finRecord := range finRecords {
+		ecnRecord, _ := data.EncodeLogRecord(finRecord)
+		if err := mergeFinFile.Write(ecnRecord); err != nil {
+			return err
+		}
+	}
+
+	return mergeFinFile.Sync()
+}
+
+// write records which keydir points at to merge db,
+// and write their new positions to hint file
+// return the number of merged data files and keys dropped due to expiration
+func (db *DB) writeMergeFiles(mergePath string, mergeFiles []*data.DataFile) (uint32, [][]byte, error) {
 	mergeOptions := db.options
 	mergeOptions.DirPath = mergePath
 	mergeOptions.SyncWrite = false
+	// index of merge db is never used
+	mergeOptions.Index = index.BTREE
+	mergeOptions.AutoMergeInterval = 0
 
 	mergeDB, err := OpenDB(mergeOptions)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
-	hintFile, err := data.OpenHintFile(mergePath)
+	defer mergeDB.Close()
+
+	hintFile, err := db.openHintFile(mergePath, fio.StandFileIO)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
+	defer hintFile.Close()
+	if err := hintFile.InitFileHeader(db.keyring); err != nil {
+		return 0, nil, err
+	}
+
+	var expiredKeys [][]byte
 
 	// write all valid record to mergeDN
 	// write all index record pos to hint file
 	for _, dataFile := range mergeFiles {
-		var offset int64 = 0
+		var offset = dataFile.HeaderSize
 		for {
 			logRecord, size, err := dataFile.ReadLogRecord(offset)
 			if err == io.EOF {
 				break
 			}
 			if err != nil {
-				return err
+				return 0, nil, err
 			}
 			realkey, _ := parseLogRecordWithSeq(logRecord.Key)
-			// TO FIX: index may be change
-			// if some item be alterd now, maybe lost it in merge file
-			// however, this item musb be persist to datafile after nonMergeFileId
-			pos := db.index.Get(realkey)
+			// key overwritten after this check keeps its new position at install
+			db.mu.RLock()
+			pos, err := db.index.Get(realkey)
+			db.mu.RUnlock()
+			if err != nil {
+				return 0, nil, err
+			}
 
-			// if log record is newest record of key
-			if pos != nil && pos.FileId == dataFile.FileId && pos.Offset == offset {
+			// if log record is newest record of key, expired record is dropped
+			if pos != nil && pos.FileId == dataFile.FileId && pos.Offset == offset && pos.Expired() {
+				expiredKeys = append(expiredKeys, realkey)
+			} else if pos != nil && pos.FileId == dataFile.FileId && pos.Offset == offset {
 				logRecord.Key = logRecordKeyWithSeq(realkey, nonTxnSeqno)
 				// append log record to mergedb active datafile
 				pos, err := mergeDB.appendLogRecord(logRecord)
 				if err != nil {
-					return err
+					return 0, nil, err
 				}
 				// append index logrecord pos to hint file
 				if err := hintFile.WriteHintRecord(realkey, pos); err != nil {
-					return err
+					return 0, nil, err
 				}
 
 			}
@@ -165,36 +255,129 @@ func (db *DB) Merge() error {
 
 	// sync hint file and merge db
 	if err := hintFile.Sync(); err != nil {
-		return err
+		return 0, nil, err
 	}
 
 	if err := mergeDB.Sync(); err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	// write fin file to present merge success
-	mergeFinFile, err := data.OpenMergeFinFile(mergePath)
-	if err != nil {
+	var mergedFiles uint32 = 0
+	if mergeDB.activeFile != nil {
+		mergedFiles = mergeDB.activeFile.FileId + 1
+	}
+
+	return mergedFiles, expiredKeys, nil
+}
+
+// install merge files to db, caller must hold db.mu
+// data files before nonMergeFid are replaced by merged files, files still
+// referenced by snapshots are closed after snapshots released
+func (db *DB) installMergeFiles(nonMergeFid, mergedFiles uint32, expiredKeys [][]byte) error {
+	if _, err := db.loaderMergeFiles(); err != nil {
 		return err
 	}
 
-	finRecord := &data.LogRecord{
-		Key:   []byte(MergeFinKey),
-		Value: []byte(strconv.Itoa(int(nonMergeFid))),
+	// open all merged files before replacing old files, so keydir still
+	// points at open files if any of them fails
+	newFiles := make([]*data.DataFile, 0, mergedFiles)
+	for fid := uint32(0); fid < mergedFiles; fid++ {
+		dataFile, err := db.openDataFile(db.options.DirPath, fid, db.fileIOType())
+		if err != nil {
+			for _, file := range newFiles {
+				_ = file.Close()
+			}
+			return err
+		}
+		newFiles = append(newFiles, dataFile)
 	}
-	ecnRecord, _ := data.EncodeLogRecord(finRecord)
 
-	if err := mergeFinFile.Write(ecnRecord); err != nil {
-		return err
+	// ids of merged files are reused by new files
+	if db.valueCache != nil {
+		db.valueCache.removeBefore(nonMergeFid)
 	}
 
-	if err := mergeFinFile.Sync(); err != nil {
+	var retiredNum int
+	var retiredSize, mergedSize int64
+	for fid, file := range db.olderFiles {
+		if fid < nonMergeFid {
+			retiredNum++
+			retiredSize += file.WriteOff
+			db.retiredFiles = append(db.retiredFiles, file)
+			delete(db.olderFiles, fid)
+		}
+	}
+
+	for _, dataFile := range newFiles {
+		mergedSize += dataFile.WriteOff
+		db.olderFiles[dataFile.FileId] = dataFile
+	}
+
+	// keys written during merge keep their newest positions
+	err := db.readHintFile(func(key []byte, pos *data.LogRecordPos) error {
+		oldPos, err := db.index.Get(key)
+		if err == nil && oldPos != nil && oldPos.FileId < nonMergeFid {
+			_, err = db.index.Put(key, pos)
+		}
 		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expiredKeys {
+		oldPos, err := db.index.Get(key)
+		if err == nil && oldPos != nil && oldPos.FileId < nonMergeFid {
+			_, err = db.index.Delete(key)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
+	// invalid records in old files are reclaimed
+	db.reclaimSize += mergedSize - retiredSize
+	if db.reclaimSize < 0 {
+		db.reclaimSize = 0
+	}
+
+	db.closeRetiredFiles()
+
+	logrus.Infof("[Bitcask] Merge installed, %v files replaced by %v files", retiredNum, mergedFiles)
+
 	return nil
 }
 
+// close files replaced by merge which aren't referenced by any snapshot
+// caller must hold db.mu
+func (db *DB) closeRetiredFiles() {
+	if len(db.retiredFiles) == 0 {
+		return
+	}
+	// retired file may be synced by group commit
+	db.groupCommit.fileRefs.Lock()
+	defer db.groupCommit.fileRefs.Unlock()
+
+	var inUse []*data.DataFile
+	for _, file := range db.retiredFiles {
+		referenced := false
+		for snap := range db.snapshots {
+			if snap.files[file.FileId] == file {
+				referenced = true
+				break
+			}
+		}
+
+		if referenced {
+			inUse = append(inUse, file)
+		} else if err := file.Close(); err != nil {
+			logrus.Warnf("[Bitcask] failed to close merged file %v: %v", file.FileId, err)
+		}
+	}
+
+	db.retiredFiles = inUse
+}
+
 // normal: tmp/bitcask
 // merge: tmp/bitcaskmerge
 func (db *DB) getMergePath() string {
@@ -205,54 +388,52 @@ func (db *DB) getMergePath() string {
 	return filepath.Join(dir, base+MergeDir)
 }
 
-// load merge file to datafile path
-func (db *DB) loaderMergeFiles() error {
+// load merge file to datafile path, return true if merge files are loaded
+//
+// merged data files replace old files with same id, fin file is moved at last,
+// so loading can be retried if process crashes halfway
+func (db *DB) loaderMergeFiles() (bool, error) {
 	mergePath := db.getMergePath()
 	if _, err := os.Stat(mergePath); os.IsNotExist(err) {
-		return nil
+		return false, nil
 	}
 
-	defer func() {
-		logrus.Infof("[Bitcask] Remove Merge Path %v", mergePath)
-		_ = os.RemoveAll(mergePath)
-	}()
-
 	dirEntries, err := os.ReadDir(mergePath)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// check merge finish
 	var mergeFinished bool
 	var mergefileNames []string
+	var dataFileNum uint32
 
 	for _, ent := range dirEntries {
 		if ent.Name() == data.HintFinFileName {
 			mergeFinished = true
+			continue
 		}
 		if ent.Name() == fileLockName {
 			continue
 		}
+		if strings.HasSuffix(ent.Name(), data.DataFileSuffix) {
+			dataFileNum++
+		}
 		mergefileNames = append(mergefileNames, ent.Name())
 	}
 
 	if !mergeFinished {
-		return nil
+		logrus.Infof("[Bitcask] Remove Merge Path %v", mergePath)
+		return false, os.RemoveAll(mergePath)
 	}
 
-	nonMergeFileId, err := db.getNonMergeFileId(mergePath)
+	nonMergeFileId, mergedFiles, err := readMergeFinFile(mergePath)
 	if err != nil {
-		return err
+		return false, err
 	}
-
-	var fileId uint32 = 0
-	for ; fileId < nonMergeFileId; fileId++ {
-		filename := data.GetDataFileName(db.options.DirPath, fileId)
-		if _, err := os.Stat(filename); err == nil {
-			if err := os.Remove(filename); err != nil {
-				return err
-			}
-		}
+	// fin file written by old version doesn't contain number of merged files
+	if mergedFiles == 0 {
+		mergedFiles = dataFileNum
 	}
 
 	// move merge file to data
@@ -260,47 +441,94 @@ func (db *DB) loaderMergeFiles() error {
 		srcPath := filepath.Join(mergePath, fileName)
 		dstPath := filepath.Join(db.options.DirPath, fileName)
 		if err := os.Rename(srcPath, dstPath); err != nil {
-			return err
+			return false, err
 		}
 	}
 
-	return nil
+	// remove old files which aren't replaced
+	for fileId := mergedFiles; fileId < nonMergeFileId; fileId++ {
+		filename := data.GetDataFileName(db.options.DirPath, fileId)
+		if _, err := os.Stat(filename); err == nil {
+			if err := os.Remove(filename); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	srcPath := filepath.Join(mergePath, data.HintFinFileName)
+	dstPath := filepath.Join(db.options.DirPath, data.HintFinFileName)
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return false, err
+	}
+
+	logrus.Infof("[Bitcask] Remove Merge Path %v", mergePath)
+	return true, os.RemoveAll(mergePath)
 }
 
 // get file id of first file which hasn't been merging
 func (db *DB) getNonMergeFileId(dirPath string) (uint32, error) {
-	finFile, err := data.OpenMergeFinFile(dirPath)
+	nonMergeFid, _, err := readMergeFinFile(dirPath)
+	return nonMergeFid, err
+}
+
+// read first file id which hasn't been merging and the number of merged files,
+// the number is 0 if fin file doesn't contain it
+func readMergeFinFile(dirPath string) (uint32, uint32, error) {
+	finFile, err := data.OpenMergeFinFile(dirPath, fio.ReadOnlyFileIO)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
+	defer finFile.Close()
 
-	record, _, err := finFile.ReadLogRecord(0)
+	record, size, err := finFile.ReadLogRecord(0)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	nonMergeFid, err := strconv.Atoi(string(record.Value))
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	return uint32(nonMergeFid), nil
+	record, _, err = finFile.ReadLogRecord(size)
+	if err == io.EOF {
+		return uint32(nonMergeFid), 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	mergedFiles, err := strconv.Atoi(string(record.Value))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return uint32(nonMergeFid), uint32(mergedFiles), nil
 }
 
 // load index from hintfile
 func (db *DB) loadIndexFromHintFile() error {
+	return db.readHintFile(func(key []byte, pos *data.LogRecordPos) error {
+		_, err := db.index.Put(key, pos)
+		return err
+	})
+}
+
+// call fn with every item in hint file, nothing to do if hint file doesn't exist
+func (db *DB) readHintFile(fn func(key []byte, pos *data.LogRecordPos) error) error {
 	hintFileName := filepath.Join(db.options.DirPath, data.HintFileName)
 	if _, err := os.Stat(hintFileName); os.IsNotExist(err) {
 		return nil
 	}
 
-	hintFile, err := data.OpenHintFile(db.options.DirPath)
+	hintFile, err := db.openHintFile(db.options.DirPath, fio.ReadOnlyFileIO)
 	if err != nil {
 		return err
 	}
+	defer hintFile.Close()
 	logrus.Infof("[Bitcask] Open hint file %v", hintFileName)
 
-	var offset int64 = 0
+	var offset = hintFile.HeaderSize
 	for {
 		record, size, err := hintFile.ReadLogRecord(offset)
 		if err != nil {
@@ -310,8 +538,9 @@ func (db *DB) loadIndexFromHintFile() error {
 			return err
 		}
 
-		pos := data.DecodeLogRecordPos(record.Value)
-		db.index.Put(record.Key, pos)
+		if err := fn(record.Key, data.DecodeLogRecordPos(record.Value)); err != nil {
+			return err
+		}
 
 		offset += size
 	}
@@ -0,0 +1,78 @@
+package bitcaskgo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// start a background goroutine which merges db periodically,
+// it is stopped by Close
+func (db *DB) startAutoMerge() {
+	db.autoMergeStop = make(chan struct{})
+	db.autoMergeWg = new(sync.WaitGroup)
+
+	db.autoMergeWg.Add(1)
+	go db.autoMerge()
+}
+
+// stop auto merge and wait running merge finish
+func (db *DB) stopAutoMerge() {
+	if db.autoMergeStop == nil {
+		return
+	}
+
+	close(db.autoMergeStop)
+	db.autoMergeWg.Wait()
+	db.autoMergeStop = nil
+}
+
+func (db *DB) autoMerge() {
+	defer db.autoMergeWg.Done()
+
+	ticker := time.NewTicker(db.options.AutoMergeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.autoMergeStop:
+			return
+		case now := <-ticker.C:
+			if !db.inAutoMergeWindow(now) {
+				continue
+			}
+
+			db.mu.RLock()
+			err := db.checkMergeAvaliable()
+			db.mu.RUnlock()
+
+			if err != nil {
+				if err != ErrMergeRationUnreached {
+					logrus.Errorf("[Bitcask] auto merge %v failed: %v", db.options.DirPath, err)
+				}
+				continue
+			}
+
+			logrus.Infof("[Bitcask] auto merge %v start", db.options.DirPath)
+			if err := db.Merge(); err != nil && err != ErrMergeIsPorgress {
+				logrus.Errorf("[Bitcask] auto merge %v failed: %v", db.options.DirPath, err)
+			}
+		}
+	}
+}
+
+// return true if now is in [AutoMergeStartHour, AutoMergeEndHour)
+func (db *DB) inAutoMergeWindow(now time.Time) bool {
+	start, end := db.options.AutoMergeStartHour, db.options.AutoMergeEndHour
+	hour := now.Hour()
+
+	if start == end {
+		return true
+	}
+	if start < end {
+		return hour >= start && hour < end
+	}
+	// window cross midnight, e.g. 22 ~ 6
+	return hour >= start || hour < end
+}
@@ -1,10 +1,15 @@
 package bitcaskgo
 
 import (
+	"bitcask-go/data"
+	"bitcask-go/fio"
 	"bitcask-go/utils"
+	"errors"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -216,3 +221,299 @@ func TestDB_Merge5(t *testing.T) {
 		assert.NotNil(t, val)
 	}
 }
+
+func TestDB_AutoMerge(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-auto-merge")
+	opts.DirPath = dir
+	opts.Maxsize = 1024 * 1024
+	opts.AutoMergeInterval = 50 * time.Millisecond
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 5000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(512))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 4000; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	// invalid data is reclaimed after merge
+	reclaimSize := db.Stat().ReclaimSize
+	merged := false
+	for i := 0; i < 100 && !merged; i++ {
+		time.Sleep(50 * time.Millisecond)
+		merged = db.Stat().ReclaimSize < reclaimSize
+	}
+	assert.True(t, merged)
+
+	// Close stops auto merge
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	defer func() {
+		_ = db2.Close()
+	}()
+	assert.Equal(t, 1000, len(db2.ListKeys()))
+}
+
+func TestDB_AutoMergeWindow(t *testing.T) {
+	db := &DB{options: DefaultOptions}
+	at := func(hour int) time.Time {
+		return time.Date(2023, 1, 1, hour, 30, 0, 0, time.Local)
+	}
+
+	// no window
+	assert.True(t, db.inAutoMergeWindow(at(12)))
+
+	db.options.AutoMergeStartHour = 1
+	db.options.AutoMergeEndHour = 5
+	assert.True(t, db.inAutoMergeWindow(at(1)))
+	assert.True(t, db.inAutoMergeWindow(at(4)))
+	assert.False(t, db.inAutoMergeWindow(at(5)))
+	assert.False(t, db.inAutoMergeWindow(at(0)))
+
+	// window cross midnight
+	db.options.AutoMergeStartHour = 22
+	db.options.AutoMergeEndHour = 6
+	assert.True(t, db.inAutoMergeWindow(at(23)))
+	assert.True(t, db.inAutoMergeWindow(at(3)))
+	assert.False(t, db.inAutoMergeWindow(at(6)))
+	assert.False(t, db.inAutoMergeWindow(at(12)))
+}
+
+// Merge 的过程中并发写入相同的 key, 重启后数据与 merge 前一致
+func TestDB_MergeConcurrentWrites(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-stress")
+	opts.DirPath = dir
+	opts.Maxsize = 256 * 1024
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	keyNum := 500
+	for i := 0; i < keyNum; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+
+	stop := make(chan struct{})
+	writers := new(sync.WaitGroup)
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < 3000; i++ {
+				k := (i*7 + w) % keyNum
+				key := utils.GetTestKey(k)
+				switch i % 4 {
+				case 0, 1:
+					assert.Nil(t, db.Put(key, utils.GetTestValue(k, w*10000+i)))
+				case 2:
+					err := db.Delete(key)
+					assert.True(t, err == nil || err == ErrKeyNotFound)
+				case 3:
+					wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+					assert.Nil(t, wb.Put(key, utils.GetTestValue(k, w*10000+i)))
+					assert.Nil(t, wb.Delete(utils.GetTestKey((i+w+1)%keyNum)))
+					assert.Nil(t, wb.Commit())
+				}
+			}
+		}(w)
+	}
+
+	merger := new(sync.WaitGroup)
+	merger.Add(1)
+	go func() {
+		defer merger.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			err := db.Merge()
+			assert.True(t, err == nil || err == ErrMergeIsPorgress)
+		}
+	}()
+
+	writers.Wait()
+	close(stop)
+	merger.Wait()
+
+	// merge once more after all writes
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	expected := make(map[string][]byte)
+	err = db.Fold(func(key []byte, value []byte) bool {
+		expected[string(key)] = value
+		return true
+	})
+	assert.Nil(t, err)
+
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	defer func() {
+		_ = db2.Close()
+	}()
+
+	actual := make(map[string][]byte)
+	err = db2.Fold(func(key []byte, value []byte) bool {
+		actual[string(key)] = value
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+// merge 完成后无需重启即可回收空间
+func TestDB_MergeOnline(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-online")
+	opts.DirPath = dir
+	opts.Maxsize = 64 * 1024
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 5000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 4000; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	// positions of live iterator and snapshot are created before merge
+	iter := db.NewIterator(DefaultIterOptions)
+	defer iter.Close()
+	snap := db.Snapshot()
+
+	sizeBefore, err := utils.DirSize(dir)
+	assert.Nil(t, err)
+	filesBefore := db.Stat().DataFileNum
+
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	sizeAfter, err := utils.DirSize(dir)
+	assert.Nil(t, err)
+	assert.Less(t, sizeAfter, sizeBefore)
+	assert.Less(t, db.Stat().DataFileNum, filesBefore)
+	_, err = os.Stat(db.getMergePath())
+	assert.True(t, os.IsNotExist(err))
+
+	for i := 0; i < 5000; i++ {
+		val, err := db.Get(utils.GetTestKey(i))
+		if i < 4000 {
+			assert.Equal(t, ErrKeyNotFound, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, utils.GetTestValue(i, 1), val)
+		}
+	}
+
+	idx := 4000
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		assert.Equal(t, utils.GetTestKey(idx), iter.Key())
+		val, err := iter.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(idx, 1), val)
+		idx++
+	}
+	assert.Equal(t, 5000, idx)
+
+	// retired files are kept until snapshot released
+	assert.NotEmpty(t, db.retiredFiles)
+	val, err := snap.Get(utils.GetTestKey(4500))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(4500, 1), val)
+	snap.Release()
+	assert.Empty(t, db.retiredFiles)
+
+	// write after merge and merge again
+	for i := 4000; i < 4500; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 2))
+		assert.Nil(t, err)
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+	defer func() {
+		_ = db2.Close()
+	}()
+	assert.Equal(t, 1000, len(db2.ListKeys()))
+	for i := 4000; i < 5000; i++ {
+		val, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		if i < 4500 {
+			assert.Equal(t, utils.GetTestValue(i, 2), val)
+		} else {
+			assert.Equal(t, utils.GetTestValue(i, 1), val)
+		}
+	}
+}
+
+func TestDB_MergeInstallOpenFailed(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-install")
+	opts.DirPath = dir
+	opts.Maxsize = 16 * 1024
+
+	// fail to open second merged file when it's installed to db dir
+	var failOpen int32
+	opts.IOWrapper = func(filename string, manager fio.IOManager) (fio.IOManager, error) {
+		if atomic.LoadInt32(&failOpen) == 1 && filename == data.GetDataFileName(dir, 1) {
+			_ = manager.Close()
+			return nil, errors.New("injected open failure")
+		}
+		return manager, nil
+	}
+
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 5000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+	for i := 0; i < 3000; i++ {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+
+	atomic.StoreInt32(&failOpen, 1)
+	assert.NotNil(t, db.Merge())
+	atomic.StoreInt32(&failOpen, 0)
+
+	// old files are kept, keydir still points at them
+	for i := 3000; i < 5000; i++ {
+		val, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+
+	// merged files are loaded at restart
+	assert.Nil(t, db.Close())
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 2000, len(db.ListKeys()))
+	val, err := db.Get(utils.GetTestKey(4500))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(4500, 1), val)
+}
@@ -0,0 +1,130 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"sort"
+)
+
+const (
+	multiGetMaxGap  = 4 * 1024    // records closer than it are read by one io
+	multiGetMaxSpan = 1024 * 1024 // max bytes read by one io
+)
+
+// value of keys[idx] is read from pos
+type multiGetRead struct {
+	idx int
+	pos *data.LogRecordPos
+}
+
+// get values of keys, values[i] and errs[i] are result of keys[i].
+// positions are looked up once, then records are read in order of file id and offset,
+// nearby records in the same file are read by one io
+func (db *DB) MultiGet(keys [][]byte) ([][]byte, []error) {
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.syncErr != nil {
+		for i := range errs {
+			errs[i] = db.syncErr
+		}
+		return values, errs
+	}
+
+	reads := make([]*multiGetRead, 0, len(keys))
+	for i, key := range keys {
+		if len(key) == 0 {
+			errs[i] = ErrKeyIsEmpty
+			continue
+		}
+
+		pos, err := db.index.Get(key)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if pos == nil || pos.Expired() {
+			errs[i] = ErrKeyNotFound
+			continue
+		}
+		if db.valueCache != nil {
+			if value, ok := db.valueCache.get(pos); ok {
+				values[i] = value
+				continue
+			}
+		}
+		reads = append(reads, &multiGetRead{idx: i, pos: pos})
+	}
+
+	sort.Slice(reads, func(i, j int) bool {
+		if reads[i].pos.FileId != reads[j].pos.FileId {
+			return reads[i].pos.FileId < reads[j].pos.FileId
+		}
+		return reads[i].pos.Offset < reads[j].pos.Offset
+	})
+
+	for start := 0; start < len(reads); {
+		first := reads[start].pos
+		spanEnd := first.Offset + int64(first.Size)
+
+		end := start + 1
+		for ; end < len(reads); end++ {
+			pos := reads[end].pos
+			if pos.FileId != first.FileId || pos.Offset-spanEnd > multiGetMaxGap ||
+				pos.Offset+int64(pos.Size)-first.Offset > multiGetMaxSpan {
+				break
+			}
+			if pos.Offset+int64(pos.Size) > spanEnd {
+				spanEnd = pos.Offset + int64(pos.Size)
+			}
+		}
+
+		db.readSpan(reads[start:end], first.Offset, spanEnd, values, errs)
+		start = end
+	}
+
+	return values, errs
+}
+
+// read records of reads in [spanStart, spanEnd) of one file by one io
+// caller must hold db.mu
+func (db *DB) readSpan(reads []*multiGetRead, spanStart, spanEnd int64, values [][]byte, errs []error) {
+	fileId := reads[0].pos.FileId
+	dataFile := db.olderFiles[fileId]
+	if db.activeFile != nil && db.activeFile.FileId == fileId {
+		dataFile = db.activeFile
+	}
+
+	var buf []byte
+	var err error
+	if dataFile == nil {
+		err = ErrDataFileNotFound
+	} else {
+		buf, err = dataFile.ReadNBytes(spanEnd-spanStart, spanStart)
+	}
+	if err != nil {
+		for _, read := range reads {
+			errs[read.idx] = err
+		}
+		return
+	}
+
+	for _, read := range reads {
+		begin := read.pos.Offset - spanStart
+		logRecord, _, err := dataFile.DecodeLogRecord(buf[begin:begin+int64(read.pos.Size)], read.pos.Offset)
+		if err != nil {
+			errs[read.idx] = err
+			continue
+		}
+		if logRecord.Type != data.LogRecordNormal {
+			errs[read.idx] = ErrKeyNotFound
+			continue
+		}
+		values[read.idx] = logRecord.Value
+		if db.valueCache != nil {
+			db.valueCache.put(read.pos, logRecord.Value)
+		}
+	}
+}
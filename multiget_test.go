@@ -0,0 +1,51 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/utils"
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_MultiGet(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-multiget")
+	opts.DirPath = dir
+	opts.Maxsize = 32 * 1024
+	opts.Compression = true
+	opts.EncryptKeys = map[uint32][]byte{1: bytes.Repeat([]byte{1}, 16)}
+	opts.EncryptKeyId = 1
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	for i := 100; i < 200; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), bytes.Repeat([]byte("bitcask"), 100)))
+	}
+	assert.Nil(t, db.PutWithTTL(utils.GetTestKey(200), []byte("expired"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	keys := [][]byte{nil, utils.GetTestKey(2000)}
+	for i := 999; i >= 0; i -= 3 {
+		keys = append(keys, utils.GetTestKey(i))
+	}
+	keys = append(keys, utils.GetTestKey(500))
+
+	values, errs := db.MultiGet(keys)
+	assert.Equal(t, len(keys), len(values))
+	assert.Equal(t, ErrKeyIsEmpty, errs[0])
+	for i, key := range keys[1:] {
+		val, err := db.Get(key)
+		assert.Equal(t, err, errs[i+1])
+		assert.Equal(t, val, values[i+1])
+	}
+}
@@ -1,20 +1,74 @@
 package bitcaskgo
 
 import (
+	"bitcask-go/fio"
 	"bitcask-go/index"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type Options struct {
-	DirPath        string
-	Maxsize        int64
+	DirPath string
+	Maxsize int64
+
+	// writes return after persisted, concurrent writes share one fsync.
+	// a write may be read by others before its fsync is done, if fsync fails
+	// the write returns error and db rejects all reads and writes with
+	// ErrSyncFailed until it's reopened, so unpersisted writes are never served
 	SyncWrite      bool
 	SyncThreshHold uint64 // if
 	Index          index.IndexType
 
 	MMapAtStartup bool
 	MergeRatio    float32
+
+	// io type of data files after startup, StandFileIO, WritableMemoryMapIO or BufferedIO,
+	// read-only db always uses ReadOnlyFileIO
+	IOType fio.FileIOType
+
+	// wrap io manager of every file opened by db, e.g. FaultInjector.Wrap in
+	// crash tests, files are used as they are if it's nil
+	IOWrapper fio.IOManagerWrapper
+
+	// open db without modifying dir, writes and merge are rejected,
+	// readers take no dir lock, so writer can be restarted while they are open,
+	// call Refresh to see records appended by writer
+	ReadOnly bool
+
+	// keep all data in memory, db never touches disk and DirPath is only a name,
+	// data is lost after Close. Merge and Backup are unsupported,
+	// BPLUSTREE index falls back to BTREE
+	InMemory bool
+
+	// truncate incomplete or corrupted record at the end of last data file
+	// when opening db, it may be left by crash during writing.
+	// OpenDB fails with ErrDataFileCorrupted if false
+	TruncateCorruptTail bool
+
+	// compress value by flate if value size is greater equal than threshold,
+	// value is stored raw if compression doesn't save space
+	Compression       bool
+	CompressThreshold int
+
+	// encrypt keys and values in data files and hint file by AES-GCM,
+	// new files are encrypted by key of EncryptKeyId and its id is stored
+	// in file header, old keys must be kept until files encrypted by them are merged.
+	// encryption is disabled if EncryptKeys is empty
+	EncryptKeys  map[uint32][]byte
+	EncryptKeyId uint32
+
+	// bytes of values cached in memory by record position for hot reads,
+	// hits and misses are reported by Stat. disabled if it's 0
+	ValueCacheSize int64
+
+	// check merge ratio and disk space periodically and merge in background,
+	// disabled if interval is 0
+	AutoMergeInterval time.Duration
+	// auto merge only runs in [start, end) hour of local time, e.g. 22 ~ 6,
+	// it can run at any time if start equals to end
+	AutoMergeStartHour int
+	AutoMergeEndHour   int
 }
 
 type IteratorOptions struct {
@@ -35,6 +89,22 @@ var DefaultOptions = Options{
 	Index:          index.RBTREE,
 	MMapAtStartup:  true,
 	MergeRatio:     0.5,
+	IOType:         fio.StandFileIO,
+	IOWrapper:      nil,
+
+	ReadOnly: false,
+	InMemory: false,
+
+	TruncateCorruptTail: true,
+
+	Compression:       false,
+	CompressThreshold: 256,
+
+	ValueCacheSize: 0,
+
+	AutoMergeInterval:  0,
+	AutoMergeStartHour: 0,
+	AutoMergeEndHour:   0,
 }
 
 var DefaultIterOptions = IteratorOptions{
@@ -0,0 +1,133 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ---- read-only mode ----
+//
+// read-only db never creates, truncates or merges files in dir,
+// it takes no dir lock and opens files without write permission, so it runs
+// alongside writer or on a read-only copy. records appended by writer after
+// open are loaded by Refresh
+
+// load records appended by writer since open or last refresh,
+// all files are reloaded if they have been merged by writer.
+// only available in read-only mode
+func (db *DB) Refresh() error {
+	if !db.options.ReadOnly {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	nonMergeFid, err := db.currentNonMergeFid()
+	if err != nil {
+		return err
+	}
+	if nonMergeFid != db.nonMergeFid {
+		return db.reload()
+	}
+
+	fileIds, err := getDataFileIds(db.options.DirPath)
+	if err != nil {
+		return err
+	}
+
+	// replay records after last replayed position like a checkpoint,
+	// all files are replayed if no file has been loaded
+	var checkpoint *indexCheckpoint
+	if db.activeFile != nil {
+		checkpoint = &indexCheckpoint{
+			fileId:   db.activeFile.FileId,
+			offset:   db.activeFile.WriteOff,
+			txnSeqNo: db.txnSeqNo,
+		}
+	}
+
+	// open files created by writer after last refresh
+	for i, fid := range fileIds {
+		if checkpoint != nil && uint32(fid) <= checkpoint.fileId {
+			continue
+		}
+		dataFile, err := db.openDataFile(db.options.DirPath, uint32(fid), db.fileIOType())
+		if err == data.ErrInvalidFileHeader && i == len(fileIds)-1 {
+			fileIds = fileIds[:i]
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if db.activeFile != nil {
+			db.olderFiles[db.activeFile.FileId] = db.activeFile
+		}
+		db.activeFile = dataFile
+	}
+
+	if db.activeFile == nil {
+		return nil
+	}
+
+	db.fileIds = fileIds
+	db.checkpoint = checkpoint
+
+	return db.loadIndexFromDateFile()
+}
+
+// caller must hold db.mu
+func (db *DB) currentNonMergeFid() (uint32, error) {
+	if _, err := os.Stat(filepath.Join(db.options.DirPath, data.HintFinFileName)); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return db.getNonMergeFileId(db.options.DirPath)
+}
+
+// replace all files and keydir by a new view of dir, files referenced by
+// snapshots are closed after snapshots are released
+// caller must hold db.mu
+func (db *DB) reload() error {
+	fresh := &DB{
+		options:    db.options,
+		mu:         new(sync.RWMutex),
+		olderFiles: make(map[uint32]*data.DataFile),
+		snapshots:  make(map[*Snapshot]struct{}),
+		keyring:    db.keyring,
+	}
+	if err := fresh.load(); err != nil {
+		fresh.closeOnLoadFailed()
+		return err
+	}
+
+	logrus.Infof("[Bitcask] files in %v have been merged, reload %v entries",
+		db.options.DirPath, fresh.keyNum())
+
+	if db.activeFile != nil {
+		db.retiredFiles = append(db.retiredFiles, db.activeFile)
+	}
+	for _, file := range db.olderFiles {
+		db.retiredFiles = append(db.retiredFiles, file)
+	}
+	_ = db.index.Close()
+
+	db.index = fresh.index
+	db.activeFile = fresh.activeFile
+	db.olderFiles = fresh.olderFiles
+	db.fileIds = fresh.fileIds
+	db.txnSeqNo = fresh.txnSeqNo
+	db.reclaimSize = fresh.reclaimSize
+	db.pendingTxns = fresh.pendingTxns
+	db.nonMergeFid = fresh.nonMergeFid
+	if db.valueCache != nil {
+		db.valueCache.clear()
+	}
+	db.closeRetiredFiles()
+
+	return nil
+}
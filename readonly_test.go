@@ -0,0 +1,139 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/utils"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_ReadOnly(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-readonly")
+	opts.DirPath = dir
+	opts.Maxsize = 32 * 1024
+	opts.Index = index.BPLUSTREE
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+
+	// reader opens dir held by writer
+	roOpts := opts
+	roOpts.ReadOnly = true
+	ro, err := OpenDB(roOpts)
+	assert.Nil(t, err)
+	defer ro.Close()
+
+	for i := 0; i < 100; i++ {
+		val, err := ro.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+
+	assert.Equal(t, ErrReadOnly, ro.Put(utils.GetTestKey(1), []byte("1")))
+	assert.Equal(t, ErrReadOnly, ro.Delete(utils.GetTestKey(1)))
+	assert.Equal(t, ErrReadOnly, ro.Merge())
+	wb := ro.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put(utils.GetTestKey(1), []byte("1")))
+	assert.Equal(t, ErrReadOnly, wb.Commit())
+	txn := ro.Begin()
+	_, err = txn.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Nil(t, txn.Commit())
+
+	// records appended by writer are loaded after refresh
+	for i := 100; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+	}
+	wb = db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put(utils.GetTestKey(1000), utils.GetTestValue(1000, 1)))
+	assert.Nil(t, wb.Commit())
+
+	_, err = ro.Get(utils.GetTestKey(500))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	assert.Nil(t, ro.Refresh())
+	for i := 0; i <= 1000; i++ {
+		val, err := ro.Get(utils.GetTestKey(i))
+		if i < 50 {
+			assert.Equal(t, ErrKeyNotFound, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+
+	// files merged by writer are reloaded
+	assert.Nil(t, db.Merge())
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 2)))
+	}
+	assert.Nil(t, ro.Refresh())
+	assert.Greater(t, ro.nonMergeFid, uint32(0))
+	for i := 0; i <= 1000; i++ {
+		val, err := ro.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		if i < 100 {
+			assert.Equal(t, utils.GetTestValue(i, 2), val)
+		} else {
+			assert.Equal(t, utils.GetTestValue(i, 1), val)
+		}
+	}
+}
+
+func TestDB_ReadOnlyWithoutLock(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-readonly")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+	assert.Nil(t, db.Close())
+	defer os.RemoveAll(dir)
+
+	// lock file isn't created by readers
+	assert.Nil(t, os.Remove(filepath.Join(dir, fileLockName)))
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+
+	roOpts := opts
+	roOpts.ReadOnly = true
+	ro1, err := OpenDB(roOpts)
+	assert.Nil(t, err)
+	ro2, err := OpenDB(roOpts)
+	assert.Nil(t, err)
+
+	// nothing is created in dir
+	after, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, len(entries), len(after))
+
+	// writer is restarted while readers are open
+	db, err = OpenDB(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put(utils.GetTestKey(100), utils.GetTestValue(100, 1)))
+	assert.Nil(t, db.Close())
+
+	assert.Nil(t, ro2.Refresh())
+	val, err := ro2.Get(utils.GetTestKey(100))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(100, 1), val)
+	assert.Nil(t, ro1.Close())
+	assert.Nil(t, ro2.Close())
+
+	roOpts.DirPath = dir + "-not-exist"
+	_, err = OpenDB(roOpts)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -4,7 +4,6 @@ import (
 	bitcaskgo "bitcask-go"
 	"bitcask-go/utils"
 	"log"
-	"os"
 	"testing"
 	"time"
 
@@ -13,8 +12,7 @@ import (
 
 func TestRedisStorageStructure_Get(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-get")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -45,8 +43,7 @@ func TestRedisStorageStructure_Get(t *testing.T) {
 
 func TestRedisStorageStructure_Del_Type(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-del-type")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -71,8 +68,7 @@ func TestRedisStorageStructure_Del_Type(t *testing.T) {
 
 func TestRedisStorageStructure_HGet(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-hget")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -104,8 +100,7 @@ func TestRedisStorageStructure_HGet(t *testing.T) {
 
 func TestRedisStorageStructure_HDel(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-hdel")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -134,8 +129,7 @@ func TestRedisStorageStructure_HDel(t *testing.T) {
 
 func TestRedisStorageStructure_SIsMember(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-sismember")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -165,8 +159,7 @@ func TestRedisStorageStructure_SIsMember(t *testing.T) {
 
 func TestRedisStorageStructure_SRem(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-srem")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -194,8 +187,7 @@ func TestRedisStorageStructure_SRem(t *testing.T) {
 
 func TestRedisStorageStructure_LPop(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-lpop")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -222,8 +214,7 @@ func TestRedisStorageStructure_LPop(t *testing.T) {
 
 func TestRedisStorageStructure_RPop(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-rpop")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -250,8 +241,7 @@ func TestRedisStorageStructure_RPop(t *testing.T) {
 
 func TestRedisStorageStructure_ZScore(t *testing.T) {
 	opts := bitcaskgo.DefaultOptions
-	dir, _ := os.MkdirTemp("", "bitcask-go-redis-zset")
-	opts.DirPath = dir
+	opts.InMemory = true
 	rds, err := NewRedisStorage(opts)
 	assert.Nil(t, err)
 
@@ -0,0 +1,136 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/index"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ---- point-in-time read only view of db ----
+//
+// snapshot freezes keydir and keeps data files referenced by it,
+// because log records are never modified in place,
+// reads of snapshot always see the state at creation time
+// no matter how db is updated or merged
+//
+// BTREE is cloned copy-on-write and BPLUSTREE is read in a bbolt read
+// transaction, neither copies keys. other keydirs are copied, it costs O(N)
+// and blocks all reads and writes meanwhile
+//
+// snapshot must be released after using, otherwise data files
+// replaced by merge can't be reclaimed, and writes to BPLUSTREE hang
+// if index file has to be remapped
+type Snapshot struct {
+	db    *DB
+	index index.Indexer             // frozen keydir
+	files map[uint32]*data.DataFile // data files when snapshot is created
+}
+
+// create a snapshot of current db state, see Snapshot for its cost
+func (db *DB) Snapshot() *Snapshot {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.newSnapshot()
+}
+
+// caller must hold db.mu
+func (db *DB) newSnapshot() *Snapshot {
+	var keydir index.Indexer
+	if indexer, ok := db.index.(index.SnapshotIndexer); ok {
+		var err error
+		if keydir, err = indexer.Snapshot(); err != nil {
+			logrus.Warnf("[Bitcask] failed to take snapshot of index, copy it: %v", err)
+		}
+	}
+	if keydir == nil {
+		keydir = db.copyIndex()
+	}
+
+	files := make(map[uint32]*data.DataFile, len(db.olderFiles)+1)
+	for fid, file := range db.olderFiles {
+		files[fid] = file
+	}
+	if db.activeFile != nil {
+		files[db.activeFile.FileId] = db.activeFile
+	}
+
+	snap := &Snapshot{
+		db:    db,
+		index: keydir,
+		files: files,
+	}
+	db.snapshots[snap] = struct{}{}
+
+	return snap
+}
+
+// copy all keys of keydir to a btree
+// caller must hold db.mu
+func (db *DB) copyIndex() index.Indexer {
+	keydir, _ := index.NewIndexer(index.BTREE, "")
+
+	iter := db.index.Iterator(false)
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keydir.Put(iter.Key(), iter.Value())
+	}
+	iter.Close()
+
+	return keydir
+}
+
+// get value of key at the time snapshot is created
+func (snap *Snapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+
+	pos, err := snap.index.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	snap.db.mu.RLock()
+	defer snap.db.mu.RUnlock()
+
+	return snap.getValueByPostion(pos)
+}
+
+// iterator over keys of snapshot
+func (snap *Snapshot) NewIterator(opt IteratorOptions) *Iterator {
+	iter := &Iterator{
+		indexIter: snap.index.Iterator(opt.Reverse),
+		bitcaskDB: snap.db,
+		snapshot:  snap,
+		prefix:    opt.Prefix,
+	}
+	iter.skipToNext()
+	return iter
+}
+
+// release snapshot, data files referenced only by it can be reclaimed
+func (snap *Snapshot) Release() {
+	snap.db.mu.Lock()
+	defer snap.db.mu.Unlock()
+
+	if _, ok := snap.db.snapshots[snap]; !ok {
+		return
+	}
+	delete(snap.db.snapshots, snap)
+	if err := snap.index.Close(); err != nil {
+		logrus.Warnf("[Bitcask] failed to close index of snapshot: %v", err)
+	}
+	snap.db.closeRetiredFiles()
+}
+
+// caller must hold snap.db.mu, snapshot may contain writes which failed to sync
+func (snap *Snapshot) getValueByPostion(pos *data.LogRecordPos) ([]byte, error) {
+	if snap.db.syncErr != nil {
+		return nil, snap.db.syncErr
+	}
+	return readValueFromFile(snap.files[pos.FileId], pos)
+}
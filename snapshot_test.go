@@ -0,0 +1,151 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_Snapshot_Get(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-snapshot")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+
+	snap := db.Snapshot()
+	defer snap.Release()
+
+	// update db after snapshot
+	for i := 0; i < 50; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 50; i < 150; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 2))
+		assert.Nil(t, err)
+	}
+
+	for i := 0; i < 100; i++ {
+		val, err := snap.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+	_, err = snap.Get(utils.GetTestKey(120))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	_, err = db.Get(utils.GetTestKey(10))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := db.Get(utils.GetTestKey(60))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(60, 2), val)
+
+	// merge doesn't break snapshot
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		val, err := snap.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(i, 1), val)
+	}
+}
+
+func TestDB_Snapshot_Iterator(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-snapshot-iter")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+
+	snap := db.Snapshot()
+	defer snap.Release()
+
+	err = db.Delete(utils.GetTestKey(3))
+	assert.Nil(t, err)
+	err = db.Put(utils.GetTestKey(5), utils.GetTestValue(5, 2))
+	assert.Nil(t, err)
+	err = db.Put(utils.GetTestKey(20), utils.GetTestValue(20, 2))
+	assert.Nil(t, err)
+
+	iter := snap.NewIterator(DefaultIterOptions)
+	idx := 0
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		assert.Equal(t, utils.GetTestKey(idx), iter.Key())
+		val, err := iter.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestValue(idx, 1), val)
+		idx++
+	}
+	iter.Close()
+	assert.Equal(t, 10, idx)
+
+	iterOpts := DefaultIterOptions
+	iterOpts.Reverse = true
+	iter = snap.NewIterator(iterOpts)
+	iter.Seek(utils.GetTestKey(5))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, utils.GetTestKey(5), iter.Key())
+	iter.Close()
+}
+
+func TestDB_Snapshot_IndexType(t *testing.T) {
+	for _, typ := range []index.IndexType{index.BTREE, index.BPLUSTREE, index.HASH} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-snapshot-index")
+		opts.DirPath = dir
+		opts.Index = typ
+		db, err := OpenDB(opts)
+		assert.Nil(t, err)
+
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+		}
+
+		snap := db.Snapshot()
+		for i := 0; i < 50; i++ {
+			assert.Nil(t, db.Delete(utils.GetTestKey(i)))
+		}
+		for i := 50; i < 150; i++ {
+			assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 2)))
+		}
+		assert.Nil(t, db.Merge())
+
+		for i := 0; i < 100; i++ {
+			val, err := snap.Get(utils.GetTestKey(i))
+			assert.Nil(t, err)
+			assert.Equal(t, utils.GetTestValue(i, 1), val)
+		}
+		_, err = snap.Get(utils.GetTestKey(120))
+		assert.Equal(t, ErrKeyNotFound, err)
+
+		iter := snap.NewIterator(DefaultIterOptions)
+		var count int
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			count++
+		}
+		iter.Close()
+		assert.Equal(t, 100, count)
+		snap.Release()
+
+		// unreleased snapshot doesn't block closing db
+		_ = db.Snapshot()
+		assert.Equal(t, 100, len(db.ListKeys()))
+		destroyDB(db)
+	}
+}
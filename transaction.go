@@ -1,13 +1,218 @@
 package bitcaskgo
 
+import (
+	"bitcask-go/data"
+	"sync"
+)
+
+type TxnStatus = int
+
 const (
-	Active = iota
+	Active TxnStatus = iota
 	Commit
 	Abort
 )
 
+// ---- optimistic serializable transaction ----
+//
+// writes are buffered in txn and invisible to others until commit,
+// every key read by txn is recorded with the position it has seen,
+// commit aborts txn if any of them has been modified by other writers,
+// so a committed txn is equal to execute all its operations at commit time
+//
+// committed writes are persisted like write batch: records with txn sequence number
+// and a fin record, recovery ignores records without fin record
 type Txn struct {
-	id     uint64
+	id     uint64 // sequence number of txn, assigned at commit
 	db     *DB
-	status interface{}
+	status TxnStatus
+
+	mu            *sync.Mutex
+	readSet       map[string]*data.LogRecordPos // position of keys when txn read them, nil if not exist
+	pendingWrites map[string]*data.LogRecord
+}
+
+// begin a new transaction
+func (db *DB) Begin() *Txn {
+	return &Txn{
+		db:            db,
+		status:        Active,
+		mu:            new(sync.Mutex),
+		readSet:       make(map[string]*data.LogRecordPos),
+		pendingWrites: make(map[string]*data.LogRecord),
+	}
+}
+
+// get value of key, it can see writes of txn self
+func (txn *Txn) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.status != Active {
+		return nil, ErrTxnClosed
+	}
+
+	// read your own writes
+	if record, ok := txn.pendingWrites[string(key)]; ok {
+		if record.Type == data.LogRecordDelete {
+			return nil, ErrKeyNotFound
+		}
+		return record.Value, nil
+	}
+
+	txn.db.mu.RLock()
+	defer txn.db.mu.RUnlock()
+
+	pos, err := txn.db.index.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	// only first read is recorded, following reads must see the same position
+	if _, ok := txn.readSet[string(key)]; !ok {
+		txn.readSet[string(key)] = pos
+	}
+
+	if pos == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return txn.db.getValueByPostion(pos)
+}
+
+// put <key, value> to pending writes of txn
+func (txn *Txn) Put(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.status != Active {
+		return ErrTxnClosed
+	}
+
+	txn.pendingWrites[string(key)] = &data.LogRecord{
+		Key:   key,
+		Value: value,
+		Type:  data.LogRecordNormal,
+	}
+
+	return nil
+}
+
+// put delete entry to pending writes of txn
+// delete is a blind write, key may be put by others before commit
+func (txn *Txn) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.status != Active {
+		return ErrTxnClosed
+	}
+
+	txn.pendingWrites[string(key)] = &data.LogRecord{
+		Key:  key,
+		Type: data.LogRecordDelete,
+	}
+
+	return nil
+}
+
+// check read set and commit pending writes atomically,
+// return ErrTxnConflict and abort txn if any read key has been modified
+func (txn *Txn) Commit() error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.status != Active {
+		return ErrTxnClosed
+	}
+
+	db := txn.db
+	var err error
+	if len(txn.pendingWrites) == 0 {
+		// read-only txn only validates read set, it can be committed in read-only db
+		db.mu.RLock()
+		err = txn.validateReadSet()
+		db.mu.RUnlock()
+	} else {
+		err = db.update(db.options.SyncWrite, func() error {
+			if err := txn.validateReadSet(); err != nil {
+				return err
+			}
+
+			seqNo, err := db.commitPendingWrites(txn.pendingWrites)
+			if err != nil {
+				// records without fin record will be ignored at recovery
+				return err
+			}
+			txn.id = seqNo
+
+			return nil
+		})
+	}
+	if err != nil {
+		txn.abort()
+		return err
+	}
+
+	txn.status = Commit
+	txn.pendingWrites = nil
+	txn.readSet = nil
+
+	return nil
+}
+
+// return ErrTxnConflict if any read key has been modified,
+// all writes hold db.mu, so index can't change while caller holds it
+func (txn *Txn) validateReadSet() error {
+	for key, pos := range txn.readSet {
+		curPos, err := txn.db.index.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		if !samePosition(pos, curPos) {
+			return ErrTxnConflict
+		}
+	}
+
+	return nil
+}
+
+// discard all pending writes of txn
+func (txn *Txn) Rollback() error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.status != Active {
+		return ErrTxnClosed
+	}
+
+	txn.abort()
+	return nil
+}
+
+// caller must hold txn.mu
+func (txn *Txn) abort() {
+	txn.status = Abort
+	txn.pendingWrites = nil
+	txn.readSet = nil
+}
+
+// two positions are same if both nil or point to the same record
+func samePosition(a, b *data.LogRecordPos) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.FileId == b.FileId && a.Offset == b.Offset
 }
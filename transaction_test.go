@@ -0,0 +1,144 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_Txn_Basic(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put(utils.GetTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+
+	txn := db.Begin()
+	err = txn.Put(utils.GetTestKey(2), []byte("value-2"))
+	assert.Nil(t, err)
+	err = txn.Delete(utils.GetTestKey(1))
+	assert.Nil(t, err)
+
+	// read your own writes
+	val, err := txn.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+	_, err = txn.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// uncommitted writes are invisible
+	_, err = db.Get(utils.GetTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), val)
+
+	err = txn.Commit()
+	assert.Nil(t, err)
+	assert.Equal(t, ErrTxnClosed, txn.Commit())
+
+	val, err = db.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// restart
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := OpenDB(opts)
+	assert.Nil(t, err)
+
+	val, err = db2.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+	_, err = db2.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	db = db2
+}
+
+func TestDB_Txn_Conflict(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-conflict")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put(utils.GetTestKey(1), []byte("1"))
+	assert.Nil(t, err)
+
+	// 1. read key modified by others
+	txn1 := db.Begin()
+	_, err = txn1.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	err = txn1.Put(utils.GetTestKey(1), []byte("2"))
+	assert.Nil(t, err)
+
+	txn2 := db.Begin()
+	_, err = txn2.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	err = txn2.Put(utils.GetTestKey(1), []byte("3"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, txn2.Commit())
+	assert.Equal(t, ErrTxnConflict, txn1.Commit())
+
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("3"), val)
+
+	// 2. read non-exist key which is put by others
+	txn3 := db.Begin()
+	_, err = txn3.Get(utils.GetTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+	err = txn3.Put(utils.GetTestKey(3), []byte("3"))
+	assert.Nil(t, err)
+
+	err = db.Put(utils.GetTestKey(2), []byte("2"))
+	assert.Nil(t, err)
+	assert.Equal(t, ErrTxnConflict, txn3.Commit())
+
+	_, err = db.Get(utils.GetTestKey(3))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 3. blind writes never conflict
+	txn4 := db.Begin()
+	err = txn4.Put(utils.GetTestKey(2), []byte("4"))
+	assert.Nil(t, err)
+	err = db.Put(utils.GetTestKey(2), []byte("5"))
+	assert.Nil(t, err)
+	assert.Nil(t, txn4.Commit())
+
+	val, err = db.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("4"), val)
+}
+
+func TestDB_Txn_Rollback(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-rollback")
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	txn := db.Begin()
+	err = txn.Put(utils.GetTestKey(1), []byte("1"))
+	assert.Nil(t, err)
+
+	err = txn.Rollback()
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrTxnClosed, txn.Put(utils.GetTestKey(2), []byte("2")))
+	assert.Equal(t, ErrTxnClosed, txn.Commit())
+
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
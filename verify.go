@@ -0,0 +1,286 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/fio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ---- offline verify and repair of db dir ----
+//
+// Verify walks all data files, hint file and merge fin file by ReadLogRecord
+// without opening db, nothing in the dir is modified.
+// Repair rewrites every valid record to a new dir, corrupted records and
+// uncommitted transaction records are dropped
+
+// problem found in db dir
+type VerifyIssue struct {
+	FileName string
+	Offset   int64
+	Reason   string
+}
+
+func (issue VerifyIssue) String() string {
+	return fmt.Sprintf("%v offset %v: %v", issue.FileName, issue.Offset, issue.Reason)
+}
+
+type VerifyReport struct {
+	DataFiles   int // number of data files
+	Records     int // valid records in data files
+	HintRecords int // valid records in hint file
+	Issues      []VerifyIssue
+}
+
+func (report *VerifyReport) addIssue(fileName string, offset int64, format string, args ...interface{}) {
+	report.Issues = append(report.Issues, VerifyIssue{
+		FileName: fileName,
+		Offset:   offset,
+		Reason:   fmt.Sprintf(format, args...),
+	})
+}
+
+// log record of an uncommitted transaction
+type pendingTxnRecord struct {
+	fileName string
+	offset   int64
+	key      []byte
+	record   *data.LogRecord
+}
+
+// check all files in options.DirPath, issues are reported instead of returned as error,
+// encrypt keys in options are used to read encrypted files
+func Verify(options Options) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	keyring := newKeyring(options)
+
+	fileSizes, err := walkDataFiles(options.DirPath, keyring, report, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyHintFile(options.DirPath, keyring, report, fileSizes); err != nil {
+		return nil, err
+	}
+
+	if err := verifyMergeFinFile(options.DirPath, report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// rewrite all valid records in options.DirPath to an empty dir dstDir,
+// records are replayed in the same order as OpenDB, new dir is written with the same options
+func Repair(options Options, dstDir string) (*VerifyReport, error) {
+	if entries, err := os.ReadDir(dstDir); err == nil && len(entries) > 0 {
+		return nil, errors.New("repair target dir is not empty")
+	}
+
+	dstOptions := options
+	dstOptions.DirPath = dstDir
+	dstOptions.AutoMergeInterval = 0
+	dst, err := OpenDB(dstOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	_, err = walkDataFiles(options.DirPath, newKeyring(options), report, func(key []byte, record *data.LogRecord) error {
+		if record.Type == data.LogRecordRangeDelete {
+			return dst.DeleteRange(key, record.Value)
+		}
+		// expired record is treated as a delete record
+		if record.Type == data.LogRecordDelete || (record.Expire > 0 && record.Expire <= time.Now().UnixNano()) {
+			return dst.Delete(key)
+		}
+		return dst.put(key, record.Value, record.Expire)
+	})
+	if err != nil {
+		_ = dst.Close()
+		return nil, err
+	}
+
+	if err := dst.Sync(); err != nil {
+		_ = dst.Close()
+		return nil, err
+	}
+
+	return report, dst.Close()
+}
+
+// read all data files in order of file id, apply is called with every record
+// which would be replayed by OpenDB, return size of all data files
+func walkDataFiles(dirPath string, keyring *data.Keyring, report *VerifyReport,
+	apply func(key []byte, record *data.LogRecord) error) (map[uint32]int64, error) {
+	fileIds, err := getDataFileIds(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileSizes := make(map[uint32]int64, len(fileIds))
+	txnRecords := make(map[uint64][]*pendingTxnRecord)
+
+	for _, fid := range fileIds {
+		fileName := filepath.Base(data.GetDataFileName(dirPath, uint32(fid)))
+		dataFile, err := data.OpenDataFile(dirPath, uint32(fid), fio.ReadOnlyFileIO, keyring)
+		if isFileHeaderError(err) {
+			report.addIssue(fileName, 0, "%v, file is skipped", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		report.DataFiles++
+		fileSizes[uint32(fid)] = dataFile.WriteOff
+
+		var offset = dataFile.HeaderSize
+		for {
+			logRecord, size, err := dataFile.ReadLogRecord(offset)
+			if err == io.EOF {
+				break
+			}
+			if err == data.ErrInvalidCRC {
+				report.addIssue(fileName, offset, "crc checking failed, %v bytes skipped", size)
+				offset += size
+				continue
+			}
+			if err == io.ErrUnexpectedEOF {
+				report.addIssue(fileName, offset, "incomplete record, %v bytes left",
+					dataFile.WriteOff-offset)
+				break
+			}
+			if err != nil {
+				_ = dataFile.Close()
+				return nil, err
+			}
+
+			report.Records++
+			realKey, seqNo := parseLogRecordWithSeq(logRecord.Key)
+
+			switch {
+			case seqNo == nonTxnSeqno:
+				if apply != nil {
+					if err := apply(realKey, logRecord); err != nil {
+						_ = dataFile.Close()
+						return nil, err
+					}
+				}
+			case logRecord.Type == data.LogRecordTxnFin:
+				for _, txnRecord := range txnRecords[seqNo] {
+					if apply == nil {
+						continue
+					}
+					if err := apply(txnRecord.key, txnRecord.record); err != nil {
+						_ = dataFile.Close()
+						return nil, err
+					}
+				}
+				delete(txnRecords, seqNo)
+			default:
+				txnRecords[seqNo] = append(txnRecords[seqNo], &pendingTxnRecord{
+					fileName: fileName,
+					offset:   offset,
+					key:      realKey,
+					record:   logRecord,
+				})
+			}
+
+			offset += size
+		}
+
+		if err := dataFile.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	// report orphaned transactions in order of sequence number
+	seqNos := make([]uint64, 0, len(txnRecords))
+	for seqNo := range txnRecords {
+		seqNos = append(seqNos, seqNo)
+	}
+	sort.Slice(seqNos, func(i, j int) bool { return seqNos[i] < seqNos[j] })
+
+	for _, seqNo := range seqNos {
+		first := txnRecords[seqNo][0]
+		report.addIssue(first.fileName, first.offset, "transaction %v has %v records without fin record",
+			seqNo, len(txnRecords[seqNo]))
+	}
+
+	return fileSizes, nil
+}
+
+func isFileHeaderError(err error) bool {
+	return err == data.ErrInvalidFileHeader || err == data.ErrFileIdMismatch ||
+		err == data.ErrUnsupportedFileVersion
+}
+
+// every hint entry must point to a record inside data file
+func verifyHintFile(dirPath string, keyring *data.Keyring, report *VerifyReport, fileSizes map[uint32]int64) error {
+	if _, err := os.Stat(filepath.Join(dirPath, data.HintFileName)); os.IsNotExist(err) {
+		return nil
+	}
+
+	hintFile, err := data.OpenHintFile(dirPath, fio.ReadOnlyFileIO, keyring)
+	if isFileHeaderError(err) {
+		report.addIssue(data.HintFileName, 0, "%v, file is skipped", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer hintFile.Close()
+
+	var offset = hintFile.HeaderSize
+	for {
+		record, size, err := hintFile.ReadLogRecord(offset)
+		if err == io.EOF {
+			break
+		}
+		if err == data.ErrInvalidCRC {
+			report.addIssue(data.HintFileName, offset, "crc checking failed, %v bytes skipped", size)
+			offset += size
+			continue
+		}
+		if err == io.ErrUnexpectedEOF {
+			report.addIssue(data.HintFileName, offset, "incomplete record")
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		report.HintRecords++
+		pos := data.DecodeLogRecordPos(record.Value)
+		fileSize, ok := fileSizes[pos.FileId]
+		if !ok {
+			report.addIssue(data.HintFileName, offset, "key %q points to missing data file %v",
+				record.Key, pos.FileId)
+		} else if pos.Offset+int64(pos.Size) > fileSize {
+			report.addIssue(data.HintFileName, offset, "key %q points past end of data file %v, offset %v size %v",
+				record.Key, pos.FileId, pos.Offset, pos.Size)
+		}
+
+		offset += size
+	}
+
+	return nil
+}
+
+func verifyMergeFinFile(dirPath string, report *VerifyReport) error {
+	if _, err := os.Stat(filepath.Join(dirPath, data.HintFinFileName)); os.IsNotExist(err) {
+		return nil
+	}
+
+	if _, _, err := readMergeFinFile(dirPath); err != nil {
+		report.addIssue(data.HintFinFileName, 0, "invalid merge fin record: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package bitcaskgo
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-verify")
+	opts.DirPath = dir
+	opts.Maxsize = 4 * 1024
+	db, err := OpenDB(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 400; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 200; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put(utils.GetTestKey(500), utils.GetTestValue(500, 1)))
+	assert.Nil(t, wb.Commit())
+
+	report, err := Verify(opts)
+	assert.Nil(t, err)
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, 200, report.HintRecords)
+	assert.Greater(t, report.DataFiles, 1)
+
+	// record of a transaction without fin record
+	_, err = db.appendLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq(utils.GetTestKey(600), 1000),
+		Value: utils.GetTestValue(600, 1),
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Sync())
+
+	// corrupt a value in first data file and cut a file hint file points to
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	buf[len(buf)/2] ^= 0xff
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+	assert.Nil(t, os.Truncate(data.GetDataFileName(dir, 1), data.FileHeaderSize+10))
+
+	report, err = Verify(opts)
+	assert.Nil(t, err)
+
+	var crcIssues, incompleteIssues, txnIssues, hintIssues int
+	for _, issue := range report.Issues {
+		switch {
+		case issue.FileName == data.HintFileName:
+			hintIssues++
+		case filepath.Ext(issue.FileName) != data.DataFileSuffix:
+			t.Errorf("unexpected issue %v", issue)
+		case strings.HasPrefix(issue.Reason, "incomplete"):
+			incompleteIssues++
+		default:
+			if strings.HasPrefix(issue.Reason, "crc") {
+				crcIssues++
+			} else {
+				txnIssues++
+			}
+		}
+	}
+	assert.Equal(t, 1, crcIssues)
+	assert.Equal(t, 1, incompleteIssues)
+	assert.Equal(t, 1, txnIssues)
+	assert.Greater(t, hintIssues, 0)
+
+	// valid records are rewritten to new dir
+	repairDir, _ := os.MkdirTemp("", "bitcask-go-repair")
+	defer os.RemoveAll(repairDir)
+	_, err = Repair(opts, repairDir)
+	assert.Nil(t, err)
+
+	repairOpts := DefaultOptions
+	repairOpts.DirPath = repairDir
+	repaired, err := OpenDB(repairOpts)
+	assert.Nil(t, err)
+	defer repaired.Close()
+
+	_, err = repaired.Get(utils.GetTestKey(50))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = repaired.Get(utils.GetTestKey(600))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := repaired.Get(utils.GetTestKey(500))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestValue(500, 1), val)
+
+	report, err = Verify(repairOpts)
+	assert.Nil(t, err)
+	assert.Empty(t, report.Issues)
+}
+
+func TestVerify_ReadOnlyFiles(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions are ignored for root")
+	}
+
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-verify-readonly")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+	db, err := OpenDB(opts)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(utils.GetTestKey(i), utils.GetTestValue(i, 1)))
+	}
+	assert.Nil(t, db.Close())
+
+	// backup mounted read-only
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	for _, entry := range entries {
+		assert.Nil(t, os.Chmod(filepath.Join(dir, entry.Name()), 0400))
+	}
+	assert.Nil(t, os.Chmod(dir, 0500))
+	defer os.Chmod(dir, 0700)
+
+	report, err := Verify(opts)
+	assert.Nil(t, err)
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, 1, report.DataFiles)
+}